@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// contaminationCmd represents the contamination command
+var contaminationCmd = &cobra.Command{
+	Use:   "contamination [texts file] [corpus files...]",
+	Short: "Find corpus documents that contain given short texts",
+	Long: `Check a corpus for contamination by test data: find the documents that
+contain short texts such as queries, answers or topic narratives.  A text
+is contained in a document when at least --threshold of its shingles are
+in the document.  Shingles are small, three words by default, so that
+short texts have enough of them.  Shingles in more than --max-df texts,
+like "what is the" in a large set of questions, are not counted.
+
+The texts file is read with --texts-format, by default a TSV file of id
+and text, and the corpus with --format.  Each match is printed as a
+"textid docid containment" line.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		textRead := readerFor("contamination.texts-format", "")
+		corpusRead := readerFor("contamination.format", "")
+
+		dd := newDeduper(corpusRead)
+		dd.Shingler.Size = viper.GetInt("contamination.shingle-size")
+		if dd.Shingler.Size < 1 {
+			log.Fatalf("Shingle size %d must be positive", dd.Shingler.Size)
+		}
+		dd.MaxDF = viper.GetFloat64("contamination.max-df")
+		log.Printf("Contamination shingles of %d %ss\n", dd.Shingler.Size, dd.Shingler.Unit)
+		dd.Contamination(args[:1], textRead, args[1:], viper.GetFloat64("contamination.threshold"))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(contaminationCmd)
+
+	contaminationCmd.Flags().String("texts-format", "tsv", "format of the texts file (wapo, better, better2, marco_pass, trec, warc, csv, tsv, wiki, mail)")
+	viper.BindPFlag("contamination.texts-format", contaminationCmd.Flags().Lookup("texts-format"))
+
+	contaminationCmd.Flags().StringP("format", "f", "wapo", "format of the corpus")
+	viper.BindPFlag("contamination.format", contaminationCmd.Flags().Lookup("format"))
+
+	contaminationCmd.Flags().Float64("threshold", 0.8, "fraction of a text's shingles a document must contain")
+	viper.BindPFlag("contamination.threshold", contaminationCmd.Flags().Lookup("threshold"))
+
+	contaminationCmd.Flags().Int("shingle-size", 3, "number of units in a shingle, overriding --shingle.size")
+	viper.BindPFlag("contamination.shingle-size", contaminationCmd.Flags().Lookup("shingle-size"))
+
+	contaminationCmd.Flags().Float64("max-df", 1000, "drop shingles in more than this many texts, or this fraction if less than 1, overriding --filter.max-df; 0 keeps them all")
+	viper.BindPFlag("contamination.max-df", contaminationCmd.Flags().Lookup("max-df"))
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// crossCmd represents the cross command
+var crossCmd = &cobra.Command{
+	Use:   "cross --index [files] --query [files]",
+	Short: "Find near-duplicates of one collection in another",
+	Long: `Index one collection, such as training data, and print the documents
+in it that near-duplicate each document of another, such as a test
+collection.  Only matches across the two collections are reported, as
+"queryid indexid similarity" lines, where the estimated Jaccard similarity
+is at least --lsh.threshold; neither collection is clustered.
+
+Each side has its own format, normalization profile and reader settings,
+so for instance a WARC crawl can be checked against a TSV file of passages.
+The reader settings are the usual flags of the format's command with
+"index." or "query." in front, such as --query.tsv.text 2 or
+--index.warc.uri.  --html.extract applies to both sides.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		indexRead := readerFor("cross.index-format", "index.")
+		queryRead := readerFor("cross.query-format", "query.")
+
+		profile := viper.GetString("normalize.profile")
+		indexProfile := viper.GetString("cross.index-normalize")
+		if indexProfile == "" {
+			indexProfile = profile
+		}
+		queryProfile := viper.GetString("cross.query-normalize")
+		if queryProfile == "" {
+			queryProfile = profile
+		}
+
+		dd := newDeduper(indexRead)
+		dd.Normalizer = newNormalizer(indexProfile)
+		dd.Cross(viper.GetStringSlice("cross.index"), viper.GetStringSlice("cross.query"),
+			queryRead, newNormalizer(queryProfile), viper.GetFloat64("lsh.threshold"))
+	},
+}
+
+// sideFlags adds the settings of every reader for one side of cross,
+// under the prefix.
+func sideFlags(cmd *cobra.Command, prefix string) {
+	trecFlags(cmd, prefix)
+	delimitedFlags(cmd, prefix+"csv", true)
+	delimitedFlags(cmd, prefix+"tsv", false)
+	warcFlags(cmd, prefix)
+	wikiFlags(cmd, prefix)
+	mailFlags(cmd, prefix)
+	wapoFlags(cmd, prefix)
+}
+
+func init() {
+	rootCmd.AddCommand(crossCmd)
+	sideFlags(crossCmd, "index.")
+	sideFlags(crossCmd, "query.")
+
+	crossCmd.Flags().StringSlice("index", nil, "files of the collection to index")
+	viper.BindPFlag("cross.index", crossCmd.Flags().Lookup("index"))
+	crossCmd.MarkFlagRequired("index")
+
+	crossCmd.Flags().StringSlice("query", nil, "files of the collection to look for in the index")
+	viper.BindPFlag("cross.query", crossCmd.Flags().Lookup("query"))
+	crossCmd.MarkFlagRequired("query")
+
+	crossCmd.Flags().String("index-format", "wapo", "format of the indexed collection (wapo, better, better2, marco_pass, trec, warc, csv, tsv, wiki, mail)")
+	viper.BindPFlag("cross.index-format", crossCmd.Flags().Lookup("index-format"))
+
+	crossCmd.Flags().String("query-format", "wapo", "format of the query collection")
+	viper.BindPFlag("cross.query-format", crossCmd.Flags().Lookup("query-format"))
+
+	crossCmd.Flags().String("index-normalize", "", "normalization profile for the indexed collection (default --normalize.profile)")
+	viper.BindPFlag("cross.index-normalize", crossCmd.Flags().Lookup("index-normalize"))
+
+	crossCmd.Flags().String("query-normalize", "", "normalization profile for the query collection (default --normalize.profile)")
+	viper.BindPFlag("cross.query-normalize", crossCmd.Flags().Lookup("query-normalize"))
+}
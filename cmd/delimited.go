@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bufio"
+	"log"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"nist.local/isoboroff/dedupe/lib"
+)
+
+// csvCmd represents the csv command
+var csvCmd = &cobra.Command{
+	Use:   "csv [CSV files]",
+	Short: "Dedupe a collection stored as CSV",
+	Long: `Compute near-duplicate hashes for documents stored one per row in CSV
+files.  Columns are given by zero-based index, or by name with --csv.header.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dd := newDeduper(delimitedReader("csv", ',').Read)
+		dd.Dedupe(args...)
+	},
+}
+
+// tsvCmd represents the tsv command
+var tsvCmd = &cobra.Command{
+	Use:   "tsv [TSV files]",
+	Short: "Dedupe a collection stored as TSV, such as MS MARCO v1 passages",
+	Long: `Compute near-duplicate hashes for documents stored one per line in
+tab-separated files, such as the MS MARCO v1 passages (pid, passage).
+Columns are given by zero-based index, or by name with --tsv.header.  Quote
+characters are ordinary text unless --tsv.quotes is given.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dd := newDeduper(delimitedReader("tsv", '\t').Read)
+		dd.Dedupe(args...)
+	},
+}
+
+func delimitedReader(prefix string, comma rune) lib.DelimitedReader {
+	for _, col := range []string{".id", ".text", ".title", ".date"} {
+		if i, err := strconv.Atoi(viper.GetString(prefix + col)); err == nil && i < 0 {
+			log.Fatalf("--%s%s must not be negative", prefix, col)
+		}
+	}
+	return lib.DelimitedReader{
+		Comma:       comma,
+		Header:      viper.GetBool(prefix + ".header"),
+		Quotes:      viper.GetBool(prefix + ".quotes"),
+		IdCol:       viper.GetString(prefix + ".id"),
+		TextCol:     viper.GetString(prefix + ".text"),
+		TitleCol:    viper.GetString(prefix + ".title"),
+		DateCol:     viper.GetString(prefix + ".date"),
+		DateLayout:  viper.GetString(prefix + ".date-layout"),
+		ExtractHTML: viper.GetBool("html.extract"),
+	}
+}
+
+// delimitedFlags adds the column flags for a command, under the prefix.
+func delimitedFlags(cmd *cobra.Command, prefix string, quotes bool) {
+	cmd.Flags().String(prefix+".id", "0", "index or name of the document id column")
+	viper.BindPFlag(prefix+".id", cmd.Flags().Lookup(prefix+".id"))
+
+	cmd.Flags().String(prefix+".text", "1", "index or name of the text column")
+	viper.BindPFlag(prefix+".text", cmd.Flags().Lookup(prefix+".text"))
+
+	cmd.Flags().String(prefix+".title", "", "index or name of the title column (default none)")
+	viper.BindPFlag(prefix+".title", cmd.Flags().Lookup(prefix+".title"))
+
+	cmd.Flags().String(prefix+".date", "", "index or name of the date column (default none)")
+	viper.BindPFlag(prefix+".date", cmd.Flags().Lookup(prefix+".date"))
+
+	cmd.Flags().String(prefix+".date-layout", "2006-01-02", "Go time layout of the dates in the date column")
+	viper.BindPFlag(prefix+".date-layout", cmd.Flags().Lookup(prefix+".date-layout"))
+
+	cmd.Flags().Bool(prefix+".header", false, "the first row names the columns")
+	viper.BindPFlag(prefix+".header", cmd.Flags().Lookup(prefix+".header"))
+
+	cmd.Flags().Bool(prefix+".quotes", quotes, "fields may be quoted")
+	viper.BindPFlag(prefix+".quotes", cmd.Flags().Lookup(prefix+".quotes"))
+}
+
+func init() {
+	rootCmd.AddCommand(csvCmd)
+	readers["csv"] = func(prefix string) func(*bufio.Reader, chan lib.Document) {
+		return delimitedReader(prefix+"csv", ',').Read
+	}
+	delimitedFlags(csvCmd, "csv", true)
+
+	rootCmd.AddCommand(tsvCmd)
+	readers["tsv"] = func(prefix string) func(*bufio.Reader, chan lib.Document) {
+		return delimitedReader(prefix+"tsv", '\t').Read
+	}
+	delimitedFlags(tsvCmd, "tsv", false)
+}
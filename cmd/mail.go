@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"nist.local/isoboroff/dedupe/lib"
+)
+
+// mailCmd represents the mail command
+var mailCmd = &cobra.Command{
+	Use:   "mail [mbox files or maildirs]",
+	Short: "Dedupe email in mbox files or maildirs",
+	Long: `Compute near-duplicate hashes for email messages, using the
+Message-ID as the document id.  Arguments can be mbox files, single
+message files, or directories such as maildirs, whose message files are
+read in sorted order.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dd := newDeduper(mailReader(""))
+		dd.Dedupe(mailFiles(args)...)
+	},
+}
+
+// mailReader returns a reader with the mail settings under the prefix.
+func mailReader(prefix string) func(*bufio.Reader, chan lib.Document) {
+	mr := lib.MailReader{StripQuotes: viper.GetBool(prefix + "mail.strip-quotes")}
+	return mr.Read
+}
+
+// mailFlags adds the mail settings for a command, under the prefix.
+func mailFlags(cmd *cobra.Command, prefix string) {
+	cmd.Flags().Bool(prefix+"mail.strip-quotes", false, "remove text quoted from earlier messages in replies")
+	viper.BindPFlag(prefix+"mail.strip-quotes", cmd.Flags().Lookup(prefix+"mail.strip-quotes"))
+}
+
+// mailFiles expands directories in the arguments into the files under
+// them, skipping maildir's tmp directories and hidden files.
+func mailFiles(args []string) []string {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		var found []string
+		err = filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			name := info.Name()
+			if path != arg && (name == "tmp" || name[0] == '.') {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.Mode().IsRegular() {
+				found = append(found, path)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		sort.Strings(found)
+		files = append(files, found...)
+	}
+	return files
+}
+
+func init() {
+	rootCmd.AddCommand(mailCmd)
+	readers["mail"] = mailReader
+	mailFlags(mailCmd, "")
+}
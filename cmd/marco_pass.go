@@ -2,12 +2,8 @@ package cmd
 
 import (
 	"bufio"
-	"regexp"
-	"strings"
-	"unicode"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 	"github.com/tidwall/gjson"
 
 	"nist.local/isoboroff/dedupe/lib"
@@ -20,13 +16,7 @@ var marco_passCmd = &cobra.Command{
 	Long:  `Compute near-duplicate hashes for passages in the MSMARCO collection.`,
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		lshThresh := viper.GetFloat64("lsh.threshold")
-		lshBuckets := viper.GetInt("lsh.buckets")
-		minhashSize := viper.GetInt("minhash.size")
-		lsh := lib.MakeLSHForThreshold(lshBuckets, lshThresh)
-		minhash := lib.NewMinhash(minhashSize)
-
-		dd := lib.MakeDeduper(lsh, *minhash, marco_read)
+		dd := newDeduper(marco_read)
 		dd.Dedupe(args[0])
 	},
 }
@@ -41,39 +31,15 @@ func marco_read(reader *bufio.Reader, c chan lib.Document) {
 		docid := article.Get("pid").String()
 		text := article.Get("passage").String()
 
-		var title string
-		if len(text) < 50 {
-			title = text[:]
-		} else {
-			title = text[:25]
-		}
-		title = strings.Map(func(r rune) rune {
-			if unicode.IsSpace(r) {
-				return ' '
-			} else {
-				return r
-			}
-		}, title)
-
-		text = marco_preprocess(text)
-		c <- lib.Document{text, docid, title}
+		title := lib.Snippet(text, 25)
+		c <- lib.Document{Text: text, Id: docid, Name: title, Raw: line}
 	}
 	close(c)
 }
 
-func marco_preprocess(text string) string {
-	result := strings.TrimSpace(text)
-	result = strings.ToLower(result)
-	result = html_re.ReplaceAllLiteralString(result, " ")
-	result = nonword_re.ReplaceAllLiteralString(result, " ")
-	return result
-}
-
 func init() {
-	nonword_re = regexp.MustCompile(`[^\pL]+`)
-	html_re = regexp.MustCompile(`<[^>]+?>`)
-
 	rootCmd.AddCommand(marco_passCmd)
+	readers["marco_pass"] = fixedReader(marco_read)
 
 	// Here you will define your flags and configuration settings.
 
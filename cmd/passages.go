@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"nist.local/isoboroff/dedupe/lib"
+)
+
+// passagesCmd represents the passages command
+var passagesCmd = &cobra.Command{
+	Use:   "passages [files...]",
+	Short: "Find documents that share near-duplicate passages",
+	Long: `Find pairs of documents that share passages, such as two articles with
+the same three paragraphs, even when the documents as a whole are not near
+duplicates.  Documents are split into overlapping windows of words or into
+paragraphs, and each passage is indexed with LSH.  Passages match when
+their estimated Jaccard similarity is at least --lsh.threshold.
+
+Each pair of documents is printed as a line of JSON with the character
+offsets of the aligned passages they share.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dd := newDeduper(readerFor("passages.format", ""))
+		dd.Shingler.Size = viper.GetInt("passages.shingle-size")
+		if dd.Shingler.Size < 1 {
+			log.Fatalf("Shingle size %d must be positive", dd.Shingler.Size)
+		}
+		seg, err := lib.MakeSegmenter(viper.GetString("passages.unit"), viper.GetInt("passages.min-chars"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		seg.Size = viper.GetInt("passages.window")
+		seg.Step = viper.GetInt("passages.step")
+		dd.Passages(args, seg, viper.GetFloat64("lsh.threshold"))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(passagesCmd)
+
+	passagesCmd.Flags().StringP("format", "f", "wapo", "collection format (wapo, better, better2, marco_pass, trec, warc, csv, tsv, wiki, mail)")
+	viper.BindPFlag("passages.format", passagesCmd.Flags().Lookup("format"))
+
+	passagesCmd.Flags().String("unit", "window", "passage unit: window or paragraph")
+	viper.BindPFlag("passages.unit", passagesCmd.Flags().Lookup("unit"))
+
+	passagesCmd.Flags().Int("window", 50, "words in a window")
+	viper.BindPFlag("passages.window", passagesCmd.Flags().Lookup("window"))
+
+	passagesCmd.Flags().Int("step", 25, "words between the starts of windows")
+	viper.BindPFlag("passages.step", passagesCmd.Flags().Lookup("step"))
+
+	passagesCmd.Flags().Int("min-chars", 100, "ignore passages shorter than this many characters")
+	viper.BindPFlag("passages.min-chars", passagesCmd.Flags().Lookup("min-chars"))
+
+	passagesCmd.Flags().Int("shingle-size", 5, "number of units in a shingle, overriding --shingle.size")
+	viper.BindPFlag("passages.shingle-size", passagesCmd.Flags().Lookup("shingle-size"))
+}
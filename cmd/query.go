@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"nist.local/isoboroff/dedupe/lib"
+)
+
+// queryCmd represents the query command
+var queryCmd = &cobra.Command{
+	Use:   "query [collection file] [docid...]",
+	Short: "Find the documents most similar to given documents",
+	Long: `Index a collection and print the documents similar to each of the
+given document ids.  By default this prints every document the LSH index
+finds above the Jaccard threshold.  With --top-k, it prints the k most
+similar documents with their estimated Jaccard similarity, using an LSH
+forest.  With --containment, it prints the documents that contain at least
+that fraction of the query document's shingles, using an LSH ensemble.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		dd := newDeduper(readerFor("query.format", ""))
+
+		topk := viper.GetInt("query.topk")
+		containment := viper.GetFloat64("query.containment")
+		if containment > 0 {
+			if partitions := viper.GetInt("ensemble.partitions"); partitions < 1 {
+				log.Fatalf("--ensemble.partitions %d must be at least 1", partitions)
+			}
+			ensemble := lib.MakeLSHEnsemble(viper.GetInt("minhash.size"),
+				viper.GetInt("ensemble.partitions"), viper.GetInt("ensemble.rows"))
+			dd.LookupContained(args[0], args[1:], ensemble, containment)
+		} else if topk > 0 {
+			forest := lib.MakeLSHForest(viper.GetInt("minhash.size"), viper.GetInt("forest.trees"))
+			dd.LookupTopK(args[0], args[1:], forest, topk)
+		} else {
+			dd.Lookup(args[0], args[1:])
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+
+	queryCmd.Flags().StringP("format", "f", "wapo", "collection format (wapo, better, better2, marco_pass, trec, warc, csv, tsv, wiki, mail)")
+	viper.BindPFlag("query.format", queryCmd.Flags().Lookup("format"))
+
+	queryCmd.Flags().IntP("top-k", "k", 0, "print the k most similar documents instead of all above the threshold")
+	viper.BindPFlag("query.topk", queryCmd.Flags().Lookup("top-k"))
+
+	queryCmd.Flags().Float64("containment", 0, "print documents containing at least this fraction of the query document")
+	viper.BindPFlag("query.containment", queryCmd.Flags().Lookup("containment"))
+
+	// forest.trees is the number of prefix trees in the LSH forest, see lib/lshforest.go
+	queryCmd.Flags().Int("forest.trees", 8, "number of trees in the LSH forest")
+	viper.BindPFlag("forest.trees", queryCmd.Flags().Lookup("forest.trees"))
+
+	// ensemble.partitions and ensemble.rows configure the LSH ensemble, see lib/lshensemble.go
+	queryCmd.Flags().Int("ensemble.partitions", 16, "number of set-size partitions in the LSH ensemble")
+	viper.BindPFlag("ensemble.partitions", queryCmd.Flags().Lookup("ensemble.partitions"))
+	queryCmd.Flags().Int("ensemble.rows", 8, "maximum rows per band in the LSH ensemble")
+	viper.BindPFlag("ensemble.rows", queryCmd.Flags().Lookup("ensemble.rows"))
+}
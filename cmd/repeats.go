@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"nist.local/isoboroff/dedupe/lib"
+)
+
+// repeatsCmd represents the repeats command
+var repeatsCmd = &cobra.Command{
+	Use:   "repeats [files...]",
+	Short: "Find documents that repeat their own paragraphs or sentences",
+	Long: `Find text repeated within documents, such as a paragraph a scraper
+copied several times.  Each document is split into paragraphs or sentences,
+and a segment whose estimated Jaccard similarity to an earlier one is at
+least --threshold counts as a repeat.
+
+Each document with repetition is printed as a line of JSON with its
+repetition ratio, the fraction of its segment text that is repeated, and
+the character offsets of each repeated span and of the span it repeats.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dd := newDeduper(readerFor("repeats.format", ""))
+		dd.Shingler.Size = viper.GetInt("repeats.shingle-size")
+		if dd.Shingler.Size < 1 {
+			log.Fatalf("Shingle size %d must be positive", dd.Shingler.Size)
+		}
+		seg, err := lib.MakeSegmenter(viper.GetString("repeats.unit"), viper.GetInt("repeats.min-chars"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		dd.Repeats(args, seg, viper.GetFloat64("repeats.threshold"), viper.GetFloat64("repeats.min-ratio"))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(repeatsCmd)
+
+	repeatsCmd.Flags().StringP("format", "f", "better", "collection format (wapo, better, better2, marco_pass, trec, warc, csv, tsv, wiki, mail)")
+	viper.BindPFlag("repeats.format", repeatsCmd.Flags().Lookup("format"))
+
+	repeatsCmd.Flags().String("unit", "paragraph", "segment unit: paragraph or sentence")
+	viper.BindPFlag("repeats.unit", repeatsCmd.Flags().Lookup("unit"))
+
+	repeatsCmd.Flags().Float64("threshold", 0.8, "Jaccard similarity at which a segment repeats an earlier one")
+	viper.BindPFlag("repeats.threshold", repeatsCmd.Flags().Lookup("threshold"))
+
+	repeatsCmd.Flags().Int("min-chars", 40, "ignore segments shorter than this many characters")
+	viper.BindPFlag("repeats.min-chars", repeatsCmd.Flags().Lookup("min-chars"))
+
+	repeatsCmd.Flags().Float64("min-ratio", 0, "only report documents with more repetition than this")
+	viper.BindPFlag("repeats.min-ratio", repeatsCmd.Flags().Lookup("min-ratio"))
+
+	repeatsCmd.Flags().Int("shingle-size", 3, "number of units in a shingle, overriding --shingle.size")
+	viper.BindPFlag("repeats.shingle-size", repeatsCmd.Flags().Lookup("shingle-size"))
+}
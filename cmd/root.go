@@ -16,17 +16,48 @@ limitations under the License.
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"io/ioutil"
+	"log"
 	"os"
+	"strconv"
+	"strings"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	homedir "github.com/mitchellh/go-homedir"
+
+	"nist.local/isoboroff/dedupe/lib"
 )
 
 
 var cfgFile string
 
+// readers maps collection names to functions that make their readers, so
+// that commands like query can work on any collection.  Each collection
+// command registers its reader in its init().  A reader takes its settings
+// from the viper keys under a prefix, which is "" except for the sides of
+// cross.
+var readers = map[string]func(prefix string) func(*bufio.Reader, chan lib.Document){}
+
+// readerFor returns the reader for the collection format named by the
+// viper key, with its settings under the prefix.
+func readerFor(key, prefix string) func(*bufio.Reader, chan lib.Document) {
+	format := viper.GetString(key)
+	makeReader, ok := readers[format]
+	if !ok {
+		log.Fatalf("Unknown collection format %s", format)
+	}
+	return makeReader(prefix)
+}
+
+// fixedReader is the entry in readers for a reader without settings.
+func fixedReader(readfn func(*bufio.Reader, chan lib.Document)) func(string) func(*bufio.Reader, chan lib.Document) {
+	return func(string) func(*bufio.Reader, chan lib.Document) {
+		return readfn
+	}
+}
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -72,12 +103,199 @@ func init() {
 	viper.BindPFlag("minhash.size", rootCmd.PersistentFlags().Lookup("minhash.size"))
 	viper.SetDefault("minhash.size", "256")
 
+	// normalize.profile names the text normalization steps to use, see lib/normalize.go.
+	// Profiles can be defined in the config file as lists of steps under normalize.profiles.
+	rootCmd.PersistentFlags().String("normalize.profile", "legacy", "text normalization profile (legacy, unicode, none, or one from the config file)")
+	viper.BindPFlag("normalize.profile", rootCmd.PersistentFlags().Lookup("normalize.profile"))
+
+	// html.extract has readers pull the main text out of HTML fields, see lib/html.go
+	rootCmd.PersistentFlags().Bool("html.extract", false, "extract main text from HTML in TREC text tags, CSV/TSV text columns and WaPo content, dropping scripts, navigation and boilerplate (WARC and mail HTML always is)")
+	viper.BindPFlag("html.extract", rootCmd.PersistentFlags().Lookup("html.extract"))
+
+	// shingle.size and shingle.unit set how documents are shingled, see lib/shingle.go
+	rootCmd.PersistentFlags().Int("shingle.size", 9, "number of units in a shingle")
+	viper.BindPFlag("shingle.size", rootCmd.PersistentFlags().Lookup("shingle.size"))
+
+	rootCmd.PersistentFlags().String("shingle.unit", "word", "shingle unit (word, char, sentence); char counts Unicode characters")
+	viper.BindPFlag("shingle.unit", rootCmd.PersistentFlags().Lookup("shingle.unit"))
+
+	// tokenize.ngram is the character n-gram size for scripts without spaces, see lib/tokenize.go
+	rootCmd.PersistentFlags().Int("tokenize.ngram", lib.DefaultTokenizer.NGram, "character n-gram size for Chinese, Japanese, Thai and other scripts without spaces")
+	viper.BindPFlag("tokenize.ngram", rootCmd.PersistentFlags().Lookup("tokenize.ngram"))
+
+	// dedupe.exact groups byte-identical documents before shingling, see lib/deduper.go
+	rootCmd.PersistentFlags().Bool("dedupe.exact", true, "group exact duplicates by hashing the text before shingling")
+	viper.BindPFlag("dedupe.exact", rootCmd.PersistentFlags().Lookup("dedupe.exact"))
+
+	rootCmd.PersistentFlags().String("dedupe.exact-out", "", "file to write exact-duplicate groups to")
+	viper.BindPFlag("dedupe.exact-out", rootCmd.PersistentFlags().Lookup("dedupe.exact-out"))
+
+	// emit-deduped writes the representative of each cluster, see lib/emit.go
+	rootCmd.PersistentFlags().String("emit-deduped", "", "file to write the original record of one document per cluster to")
+	viper.BindPFlag("dedupe.emit-deduped", rootCmd.PersistentFlags().Lookup("emit-deduped"))
+
+	rootCmd.PersistentFlags().Bool("emit-annotate", false, "add cluster and cluster_size fields to emitted JSON records")
+	viper.BindPFlag("dedupe.emit-annotate", rootCmd.PersistentFlags().Lookup("emit-annotate"))
+
+	// dedupe.representative chooses the document that stands for each cluster, see lib/represent.go
+	rootCmd.PersistentFlags().String("dedupe.representative", "first", "cluster representative: first, longest, earliest, latest, smallest-id, centroid or source")
+	viper.BindPFlag("dedupe.representative", rootCmd.PersistentFlags().Lookup("dedupe.representative"))
+
+	rootCmd.PersistentFlags().StringSlice("dedupe.sources", nil, "sources in order of preference for --dedupe.representative=source")
+	viper.BindPFlag("dedupe.sources", rootCmd.PersistentFlags().Lookup("dedupe.sources"))
+
+	// dedupe.pairs writes the candidate pairs behind each cluster, see lib/pairs.go
+	rootCmd.PersistentFlags().String("dedupe.pairs", "", "file to write each clustered candidate pair to, with its similarity and colliding bands")
+	viper.BindPFlag("dedupe.pairs", rootCmd.PersistentFlags().Lookup("dedupe.pairs"))
+
+	rootCmd.PersistentFlags().String("dedupe.pairs-format", "jsonl", "format of the pairs file: jsonl or tsv")
+	viper.BindPFlag("dedupe.pairs-format", rootCmd.PersistentFlags().Lookup("dedupe.pairs-format"))
+
+	rootCmd.PersistentFlags().Bool("dedupe.pairs-exact", false, "also compute the exact Jaccard similarity of each pair (keeps all shingles in memory)")
+	viper.BindPFlag("dedupe.pairs-exact", rootCmd.PersistentFlags().Lookup("dedupe.pairs-exact"))
+
+	// split.* break up clusters that chain unrelated documents together, see lib/split.go
+	rootCmd.PersistentFlags().Float64("split.max-diameter", 0, "split clusters with documents further apart than this (1 - Jaccard); 0 means don't split; with --split.method center it is a radius around each center")
+	viper.BindPFlag("split.max-diameter", rootCmd.PersistentFlags().Lookup("split.max-diameter"))
+
+	rootCmd.PersistentFlags().String("split.method", "complete", "how to split clusters: complete (complete-link, or center for clusters over 2000 documents) or center (faster, but the diameter is a radius)")
+	viper.BindPFlag("split.method", rootCmd.PersistentFlags().Lookup("split.method"))
+
+	// dedupe.thresholds clusters at several thresholds at once, see lib/levels.go
+	rootCmd.PersistentFlags().StringSlice("dedupe.thresholds", nil, "cluster at each of these Jaccard thresholds in one run, e.g. 0.5,0.7,0.9, as single-link clusters of verified pairs (overrides lsh.threshold)")
+	viper.BindPFlag("dedupe.thresholds", rootCmd.PersistentFlags().Lookup("dedupe.thresholds"))
+
+	// dedupe.stats-json writes the end-of-run statistics for dashboards, see lib/stats.go
+	rootCmd.PersistentFlags().String("dedupe.stats-json", "", "file to write cluster statistics and phase timings to as JSON")
+	viper.BindPFlag("dedupe.stats-json", rootCmd.PersistentFlags().Lookup("dedupe.stats-json"))
+
+	// filter.* drop boilerplate shingles before minhashing, see lib/filter.go
+	rootCmd.PersistentFlags().Float64("filter.max-df", 0, "drop shingles in more than this many documents, or this fraction if less than 1")
+	viper.BindPFlag("filter.max-df", rootCmd.PersistentFlags().Lookup("filter.max-df"))
+
+	rootCmd.PersistentFlags().String("filter.stopwords", "", "drop word shingles starting with a stopword: \"english\" or a file of stopwords")
+	viper.BindPFlag("filter.stopwords", rootCmd.PersistentFlags().Lookup("filter.stopwords"))
+
+	rootCmd.PersistentFlags().String("filter.report", "", "file to write shingles dropped for document frequency to")
+	viper.BindPFlag("filter.report", rootCmd.PersistentFlags().Lookup("filter.report"))
+
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	// rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
 
+// newNormalizer makes the Normalizer for a profile, looking first in the
+// config file and then at the built-in profiles.
+func newNormalizer(profile string) lib.Normalizer {
+	key := "normalize.profiles." + profile
+	steps, ok := lib.NormalizeProfiles[profile]
+	if viper.IsSet(key) {
+		steps, ok = viper.GetStringSlice(key), true
+	}
+	if !ok {
+		log.Fatalf("Unknown normalization profile %s", profile)
+	}
+	n, err := lib.MakeNormalizer(steps)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Normalizing with %s: %s\n", profile, n)
+	return n
+}
+
+// newDeduper makes a Deduper for the reader using the normalization, LSH,
+// minhash and shingling settings from the config.
+func newDeduper(readfn func(*bufio.Reader, chan lib.Document)) *lib.Deduper {
+	lshThresh := viper.GetFloat64("lsh.threshold")
+	thresholds := viper.GetStringSlice("dedupe.thresholds")
+	var levels []float64
+	for _, t := range thresholds {
+		level, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			log.Fatalf("Bad threshold %s: %v", t, err)
+		}
+		levels = append(levels, level)
+		if len(levels) == 1 || level < lshThresh {
+			lshThresh = level
+		}
+	}
+	lshBuckets := viper.GetInt("lsh.buckets")
+	minhashSize := viper.GetInt("minhash.size")
+	lsh := lib.MakeLSHForThreshold(lshBuckets, lshThresh)
+	minhash := lib.NewMinhash(minhashSize)
+
+	shingler, err := lib.MakeShingler(viper.GetInt("shingle.size"), viper.GetString("shingle.unit"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	shingler.Tokenizer.NGram = viper.GetInt("tokenize.ngram")
+
+	dd := lib.MakeDeduper(lsh, *minhash, readfn)
+	dd.Normalizer = newNormalizer(viper.GetString("normalize.profile"))
+	if shingler.Unit == lib.SENTENCE && !dd.Normalizer.KeepsSentences() {
+		log.Fatalf("Sentence shingles need sentence punctuation, but normalization profile %s removes it; use a profile with punct:sentence instead",
+			viper.GetString("normalize.profile"))
+	}
+	dd.Shingler = shingler
+	dd.Exact = viper.GetBool("dedupe.exact")
+	dd.ExactFile = viper.GetString("dedupe.exact-out")
+	dd.MaxDF = viper.GetFloat64("filter.max-df")
+	dd.Stopwords = readStopwords(viper.GetString("filter.stopwords"))
+	dd.FilterReport = viper.GetString("filter.report")
+	dd.EmitFile = viper.GetString("dedupe.emit-deduped")
+	dd.Annotate = viper.GetBool("dedupe.emit-annotate")
+	dd.Representative, err = lib.MakeRepPolicy(viper.GetString("dedupe.representative"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	dd.Sources = viper.GetStringSlice("dedupe.sources")
+	dd.PairsFile = viper.GetString("dedupe.pairs")
+	dd.PairsFormat = viper.GetString("dedupe.pairs-format")
+	dd.PairsExact = viper.GetBool("dedupe.pairs-exact")
+	dd.StatsFile = viper.GetString("dedupe.stats-json")
+	dd.Thresholds = levels
+	dd.MaxDiameter = viper.GetFloat64("split.max-diameter")
+	dd.SplitMethod, err = lib.MakeSplitMethod(viper.GetString("split.method"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(levels) > 0 {
+		// Clustering at several thresholds only prints the clusters.
+		for _, unused := range []struct {
+			flag string
+			set  bool
+		}{
+			{"emit-deduped", dd.EmitFile != ""},
+			{"dedupe.pairs", dd.PairsFile != ""},
+			{"split.max-diameter", dd.MaxDiameter > 0},
+			{"dedupe.representative", dd.Representative != lib.FIRST},
+		} {
+			if unused.set {
+				log.Fatalf("--%s can't be used with --dedupe.thresholds", unused.flag)
+			}
+		}
+	}
+	return dd
+}
+
+// readStopwords returns the built-in English stopwords for "english", or
+// the words in the named file otherwise.
+func readStopwords(name string) []string {
+	if name == "" {
+		return nil
+	}
+	if name == "english" {
+		return lib.EnglishStopwords
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return strings.Fields(string(data))
+}
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	if cfgFile != "" {
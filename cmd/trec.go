@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"bufio"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"nist.local/isoboroff/dedupe/lib"
+)
+
+// trecCmd represents the trec command
+var trecCmd = &cobra.Command{
+	Use:   "trec [TREC SGML files]",
+	Short: "Dedupe a collection in TREC SGML format",
+	Long: `Compute near-duplicate hashes for documents in TREC SGML (TRECTEXT)
+format, such as TREC disks 4 and 5 or AQUAINT.  The files may be gzipped,
+and are read in order as one collection.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dd := newDeduper(trecReader(""))
+		dd.Dedupe(args...)
+	},
+}
+
+// trecReader returns a reader with the trec settings under the prefix.
+func trecReader(prefix string) func(*bufio.Reader, chan lib.Document) {
+	tr := lib.MakeTrecReader(viper.GetString(prefix+"trec.doc"), viper.GetString(prefix+"trec.id"),
+		viper.GetString(prefix+"trec.title"), viper.GetStringSlice(prefix+"trec.text"))
+	tr.ExtractHTML = viper.GetBool("html.extract")
+	if tag := viper.GetString(prefix + "trec.date"); tag != "" {
+		tr.SetDate(tag, viper.GetString(prefix+"trec.date-layout"))
+	}
+	return tr.Read
+}
+
+// trecFlags adds the trec settings for a command, under the prefix.
+func trecFlags(cmd *cobra.Command, prefix string) {
+	cmd.Flags().String(prefix+"trec.doc", "DOC", "tag enclosing each document")
+	viper.BindPFlag(prefix+"trec.doc", cmd.Flags().Lookup(prefix+"trec.doc"))
+	cmd.Flags().String(prefix+"trec.id", "DOCNO", "tag holding the document id")
+	viper.BindPFlag(prefix+"trec.id", cmd.Flags().Lookup(prefix+"trec.id"))
+	cmd.Flags().String(prefix+"trec.title", "HEADLINE", "tag holding the document title")
+	viper.BindPFlag(prefix+"trec.title", cmd.Flags().Lookup(prefix+"trec.title"))
+	cmd.Flags().StringSlice(prefix+"trec.text", []string{"TEXT"}, "tags holding the document text")
+	viper.BindPFlag(prefix+"trec.text", cmd.Flags().Lookup(prefix+"trec.text"))
+	cmd.Flags().String(prefix+"trec.date", "", "tag holding the document date (default none)")
+	viper.BindPFlag(prefix+"trec.date", cmd.Flags().Lookup(prefix+"trec.date"))
+	cmd.Flags().String(prefix+"trec.date-layout", "2006-01-02", "Go time layout of the dates in --"+prefix+"trec.date")
+	viper.BindPFlag(prefix+"trec.date-layout", cmd.Flags().Lookup(prefix+"trec.date-layout"))
+}
+
+func init() {
+	rootCmd.AddCommand(trecCmd)
+	readers["trec"] = trecReader
+	trecFlags(trecCmd, "")
+}
@@ -3,7 +3,7 @@ package cmd
 import (
 	"bufio"
 	"strings"
-	"regexp"
+	"time"
 	"unicode"
 
 	"github.com/spf13/cobra"
@@ -18,21 +18,43 @@ var wapoCmd = &cobra.Command{
 	Use:   "wapo [JSON lines file]",
 	Short: "Dedupe the Washington Post collection",
 	Long: `Compute near-duplicate hashes for documents in the Washington Post
-collection.`,
+collection.
+
+The text of a document is made from its text "contents" entries.  These can
+be limited with --wapo.types and --wapo.skip, which take entry types such as
+title, byline, kicker or sanitized_html, or a type and subtype such as
+sanitized_html/paragraph.  The title is included unless --wapo.title=false
+or --wapo.skip lists it.`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		lshThresh := viper.GetFloat64("lsh.threshold")
-		lshBuckets := viper.GetInt("lsh.buckets")
-		minhashSize := viper.GetInt("minhash.size")
-		lsh := lib.MakeLSHForThreshold(lshBuckets, lshThresh)
-		minhash := lib.NewMinhash(minhashSize)
-
-		dd := lib.MakeDeduper(lsh, *minhash, read)
+		dd := newDeduper(wapoReader(""))
 		dd.Dedupe(args[0])
 	},
 }
 
-func read(reader *bufio.Reader, c chan lib.Document) {
+// wapoReader returns a reader with the wapo settings under the prefix.
+func wapoReader(prefix string) func(*bufio.Reader, chan lib.Document) {
+	fields := lib.MakeWapoFields(viper.GetStringSlice(prefix+"wapo.types"),
+		viper.GetStringSlice(prefix+"wapo.skip"), viper.GetBool(prefix+"wapo.title"))
+	fields.ExtractHTML = viper.GetBool("html.extract")
+	return func(reader *bufio.Reader, c chan lib.Document) {
+		read(reader, c, fields)
+	}
+}
+
+// wapoFlags adds the wapo settings for a command, under the prefix.
+func wapoFlags(cmd *cobra.Command, prefix string) {
+	cmd.Flags().StringSlice(prefix+"wapo.types", nil, "only use contents entries of these types (default all text entries)")
+	viper.BindPFlag(prefix+"wapo.types", cmd.Flags().Lookup(prefix+"wapo.types"))
+
+	cmd.Flags().StringSlice(prefix+"wapo.skip", nil, "skip contents entries of these types, e.g. byline,kicker")
+	viper.BindPFlag(prefix+"wapo.skip", cmd.Flags().Lookup(prefix+"wapo.skip"))
+
+	cmd.Flags().Bool(prefix+"wapo.title", true, "include the title in the text, even if --"+prefix+"wapo.types doesn't list it")
+	viper.BindPFlag(prefix+"wapo.title", cmd.Flags().Lookup(prefix+"wapo.title"))
+}
+
+func read(reader *bufio.Reader, c chan lib.Document, fields *lib.WapoFields) {
 	for {
 		line, err := reader.ReadString('\n')
 		if err != nil {
@@ -48,41 +70,21 @@ func read(reader *bufio.Reader, c chan lib.Document) {
 				return r
 			}
 		}, title)
-		text := getWapoText(article)
-		text = preprocess(text)
-		c <- lib.Document{text, docid, title}
+		text := fields.Text(article)
+		doc := lib.Document{Text: text, Id: docid, Name: title, Raw: line}
+		if ms := article.Get("published_date").Int(); ms != 0 {
+			doc.Date = time.Unix(ms/1000, ms%1000*int64(time.Millisecond))
+		}
+		doc.Source = article.Get("source").String()
+		c <- doc
 	}
 	close(c)
 }
 
-func getWapoText(obj gjson.Result) string {
-	var textbuf strings.Builder
-	obj.Get("contents").ForEach(func(key, val gjson.Result) bool {
-		if strings.HasPrefix(val.Get("mime").String(), "text/") {
-			textbuf.WriteString(val.Get("content").String())
-			textbuf.WriteRune(' ')
-		}
-		return true
-	})
-	return textbuf.String()
-}
-
-var nonword_re *regexp.Regexp
-var html_re *regexp.Regexp
-
-func preprocess(text string) string {
-	result := strings.TrimSpace(text)
-	result = strings.ToLower(result)
-	result = html_re.ReplaceAllLiteralString(result, " ")
-	result = nonword_re.ReplaceAllLiteralString(result, " ")
-	return result
-}
-
 func init() {
-	nonword_re = regexp.MustCompile(`[^\pL]+`)
-	html_re = regexp.MustCompile(`<[^>]+?>`)
-	
 	rootCmd.AddCommand(wapoCmd)
+	readers["wapo"] = wapoReader
+	wapoFlags(wapoCmd, "")
 
 	// Here you will define your flags and configuration settings.
 
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"bufio"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"nist.local/isoboroff/dedupe/lib"
+)
+
+// warcCmd represents the warc command
+var warcCmd = &cobra.Command{
+	Use:   "warc [WARC or WET files]",
+	Short: "Dedupe a web crawl in WARC or WET format",
+	Long: `Compute near-duplicate hashes for the pages in WARC files, using the
+main text extracted from each HTML response, or in Common Crawl WET files.
+The files may be gzipped, and are read in order as one collection.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dd := newDeduper(warcReader(""))
+		dd.Dedupe(args...)
+	},
+}
+
+// warcReader returns a reader with the warc settings under the prefix.
+func warcReader(prefix string) func(*bufio.Reader, chan lib.Document) {
+	wr := lib.WarcReader{UseURI: viper.GetBool(prefix + "warc.uri")}
+	return wr.Read
+}
+
+// warcFlags adds the warc settings for a command, under the prefix.
+func warcFlags(cmd *cobra.Command, prefix string) {
+	cmd.Flags().Bool(prefix+"warc.uri", false, "use the target URI as the document id instead of the WARC-Record-ID")
+	viper.BindPFlag(prefix+"warc.uri", cmd.Flags().Lookup(prefix+"warc.uri"))
+}
+
+func init() {
+	rootCmd.AddCommand(warcCmd)
+	readers["warc"] = warcReader
+	warcFlags(warcCmd, "")
+}
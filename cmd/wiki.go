@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"bufio"
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"nist.local/isoboroff/dedupe/lib"
+)
+
+// wikiCmd represents the wiki command
+var wikiCmd = &cobra.Command{
+	Use:   "wiki [MediaWiki XML dump files]",
+	Short: "Dedupe the pages in a Wikipedia or other MediaWiki dump",
+	Long: `Compute near-duplicate hashes for the pages in MediaWiki XML dumps,
+such as the Wikipedia pages-articles dumps, with the wikitext markup
+stripped.  The dumps may be bzip2ed or gzipped.  Each page is a document,
+using its latest revision, or with --wiki.revisions each revision is.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if viper.GetBool("wiki.revisions") && viper.GetString("dedupe.emit-deduped") != "" {
+			log.Fatal("--emit-deduped can't write revisions back out as a dump")
+		}
+		dd := newDeduper(wikiReader(""))
+		dd.Dedupe(args...)
+	},
+}
+
+// wikiReader returns a reader with the wiki settings under the prefix.
+func wikiReader(prefix string) func(*bufio.Reader, chan lib.Document) {
+	wr := lib.WikiReader{
+		Revisions:     viper.GetBool(prefix + "wiki.revisions"),
+		AllNamespaces: viper.GetBool(prefix + "wiki.all-namespaces"),
+	}
+	return wr.Read
+}
+
+// wikiFlags adds the wiki settings for a command, under the prefix.
+func wikiFlags(cmd *cobra.Command, prefix string) {
+	cmd.Flags().Bool(prefix+"wiki.revisions", false, "make a document of every revision, with id pageid#revid")
+	viper.BindPFlag(prefix+"wiki.revisions", cmd.Flags().Lookup(prefix+"wiki.revisions"))
+
+	cmd.Flags().Bool(prefix+"wiki.all-namespaces", false, "include talk, user and other non-article pages")
+	viper.BindPFlag(prefix+"wiki.all-namespaces", cmd.Flags().Lookup(prefix+"wiki.all-namespaces"))
+}
+
+func init() {
+	rootCmd.AddCommand(wikiCmd)
+	readers["wiki"] = wikiReader
+	wikiFlags(wikiCmd, "")
+}
@@ -0,0 +1,119 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// Contamination finds the documents in a corpus that contain short texts,
+// such as queries, answers or topic narratives, for checking whether test
+// data leaked into training data.  The texts are read with their own
+// reader, and the corpus with the Deduper's.  Both are normalized and
+// shingled the same way, usually with small shingles.
+//
+// Containment is the fraction of a text's shingles that are in a document.
+// The texts are few enough to keep an exact index of their shingles, so
+// containment is counted exactly rather than estimated.  Texts with fewer
+// units than the shingle size are skipped, since their one shingle would
+// never match a full-size one.  If MaxDF is set, shingles in more than
+// that many texts, or that fraction of them, are left out of the index
+// and of the texts' sizes, as they are left out of documents by Dedupe.
+// Otherwise a shingle like "what is the" in a large set of questions would
+// have every document count a hit for most of them.  A text made only of
+// such shingles keeps them all.
+//
+// It prints a "textid docid containment" line for each document that
+// contains at least the threshold fraction of a text.
+func (dd Deduper) Contamination(textFiles []string, readfn func(*bufio.Reader, chan Document), corpusFiles []string, threshold float64) {
+	dd.contamination(textFiles, readfn, corpusFiles, threshold, func(text, doc string, containment float64) {
+		fmt.Printf("%s %s %.4f\n", text, doc, containment)
+	})
+}
+
+// contamination calls found for each contained text Contamination finds,
+// in corpus order.
+func (dd Deduper) contamination(textFiles []string, readfn func(*bufio.Reader, chan Document), corpusFiles []string, threshold float64,
+	found func(text, doc string, containment float64)) {
+	log.Println("--- Indexing texts in", textFiles)
+
+	texts := dd
+	texts.readfn = readfn
+	units := Shingler{Size: 1, Unit: dd.Shingler.Unit, Tokenizer: dd.Shingler.Tokenizer}
+
+	var ids []string
+	var all [][]uint32
+	df := make(map[uint32]int)
+	short := 0
+	texts.scan(textFiles, func(doc Document) {
+		if len(units.Strings(doc.Text)) < dd.Shingler.Size {
+			short++
+			return
+		}
+		shingles := dd.Shingler.Shingle(doc.Text)
+		for _, fp := range shingles {
+			df[fp]++
+		}
+		ids = append(ids, doc.Id)
+		all = append(all, shingles)
+	})
+
+	cutoff := len(ids)
+	if dd.MaxDF > 0 {
+		cutoff = dfCutoff(dd.MaxDF, len(ids))
+		dropped := 0
+		for _, n := range df {
+			if n > cutoff {
+				dropped++
+			}
+		}
+		log.Printf("Dropping %d shingles in more than %d texts\n", dropped, cutoff)
+	}
+	sizes := make([]int, len(ids))
+	index := make(map[uint32][]int)
+	for t, shingles := range all {
+		var kept []uint32
+		for _, fp := range shingles {
+			if df[fp] <= cutoff {
+				kept = append(kept, fp)
+			}
+		}
+		if len(kept) == 0 {
+			kept = shingles
+		}
+		for _, fp := range kept {
+			index[fp] = append(index[fp], t)
+		}
+		sizes[t] = len(kept)
+	}
+	all, df = nil, nil
+	log.Println(len(ids), "texts indexed")
+	if short > 0 {
+		log.Println(short, "texts shorter than a shingle skipped")
+	}
+
+	log.Println("--- Searching corpus", corpusFiles)
+
+	contained := 0
+	dd.scan(corpusFiles, func(doc Document) {
+		counts := make(map[int]int)
+		for _, fp := range dd.Shingler.Shingle(doc.Text) {
+			for _, t := range index[fp] {
+				counts[t]++
+			}
+		}
+		var hits []int
+		for t, n := range counts {
+			if float64(n)/float64(sizes[t]) >= threshold {
+				hits = append(hits, t)
+			}
+		}
+		sort.Ints(hits)
+		for _, t := range hits {
+			found(ids[t], doc.Id, float64(counts[t])/float64(sizes[t]))
+		}
+		contained += len(hits)
+	})
+	log.Println(contained, "contained texts found")
+}
@@ -0,0 +1,60 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContamination(t *testing.T) {
+	tsv := DelimitedReader{Comma: '\t', IdCol: "0", TextCol: "1"}
+	dir := t.TempDir()
+	question := words(0, 8)
+	doc := append(append(words(100, 30), question...), words(200, 30)...)
+	texts := writeTSV(t, dir, "texts.tsv",
+		"leaked\t"+strings.Join(question, " "),
+		"unseen\t"+strings.Join(words(300, 8), " "),
+		"tiny\tw0 w1")
+	corpus := writeTSV(t, dir, "corpus.tsv",
+		"d1\t"+strings.Join(doc, " "),
+		"d2\t"+strings.Join(words(400, 60), " "))
+
+	dd := MakeDeduper(MakeLSH(128, 32), *NewMinhash(128), tsv.Read)
+	dd.Normalizer = Normalizer{}
+	dd.Shingler.Size = 3
+	var got []string
+	dd.contamination([]string{texts}, tsv.Read, []string{corpus}, 0.8, func(text, doc string, containment float64) {
+		if containment != 1.0 {
+			t.Errorf("%s is in %s with containment %f, want 1", text, doc, containment)
+		}
+		got = append(got, text+" "+doc)
+	})
+	if strings.Join(got, ",") != "leaked d1" {
+		t.Errorf("Got %v, want only leaked d1", got)
+	}
+}
+
+func TestContaminationMaxDF(t *testing.T) {
+	tsv := DelimitedReader{Comma: '\t', IdCol: "0", TextCol: "1"}
+	dir := t.TempDir()
+	texts := writeTSV(t, dir, "texts.tsv",
+		"q1\twhat is the capital of france",
+		"q2\twhat is the tallest mountain",
+		"q3\twhat is the speed of light",
+		"q4\twhat is the")
+	corpus := writeTSV(t, dir, "corpus.tsv",
+		"d1\twe ask what is the answer",
+		"d2\tparis is the capital of france")
+
+	dd := MakeDeduper(MakeLSH(128, 32), *NewMinhash(128), tsv.Read)
+	dd.Normalizer = Normalizer{}
+	dd.Shingler.Size = 3
+	dd.MaxDF = 2
+	var got []string
+	dd.contamination([]string{texts}, tsv.Read, []string{corpus}, 0.8, func(text, doc string, containment float64) {
+		got = append(got, text+" "+doc)
+	})
+	// "what is the" isn't counted, except for q4, which has nothing else.
+	if strings.Join(got, ",") != "q4 d1,q1 d2" {
+		t.Errorf("Got %v, want q4 d1 and q1 d2", got)
+	}
+}
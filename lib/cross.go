@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// Cross finds documents in one collection that near-duplicate documents in
+// another, without clustering either collection.  The index collection is
+// read with the Deduper's reader and normalizer, and the query collection
+// with its own, so the two can be in different formats.  Any shingle
+// filter is built from the index collection and used for both.
+//
+// It prints a "queryid indexid similarity" line for each index document
+// LSH finds for a query document whose Jaccard similarity, estimated from
+// their signatures, is at least the threshold.
+func (dd Deduper) Cross(indexFiles, queryFiles []string, readfn func(*bufio.Reader, chan Document), normalizer Normalizer, threshold float64) {
+	dd.cross(indexFiles, queryFiles, readfn, normalizer, threshold, func(query, index string, sim float64) {
+		fmt.Printf("%s %s %.4f\n", query, index, sim)
+	})
+}
+
+// cross calls match for each match Cross finds, in query order.
+func (dd Deduper) cross(indexFiles, queryFiles []string, readfn func(*bufio.Reader, chan Document), normalizer Normalizer, threshold float64,
+	match func(query, index string, sim float64)) {
+	dd.filter = dd.makeFilter(indexFiles)
+
+	log.Println("--- Indexing", indexFiles)
+
+	sigs := make(map[string][]uint32)
+	dd.scan(indexFiles, func(doc Document) {
+		s := dd.Fingerprint(dd.shingle(doc.Text))
+		dd.Index(doc.Id, s)
+		sigs[doc.Id] = s
+	})
+	dd.filter.closeReport()
+
+	log.Println("--- Querying with", queryFiles)
+
+	query := dd
+	query.readfn = readfn
+	query.Normalizer = normalizer
+	matched, matches, rejected := 0, 0, 0
+	query.scan(queryFiles, func(doc Document) {
+		s := query.Fingerprint(query.shingle(doc.Text))
+		found := query.Query(s)
+		sort.Strings(found)
+		n := 0
+		for _, d := range found {
+			// LSH also finds some documents below the threshold.
+			sim := EstimateJaccard(s, sigs[d])
+			if sim < threshold {
+				rejected++
+				continue
+			}
+			match(doc.Id, d, sim)
+			n++
+		}
+		if n > 0 {
+			matched++
+			matches += n
+		}
+	})
+	log.Println(matched, "query documents matched", matches, "index documents")
+	log.Println(rejected, "candidates below the threshold rejected")
+}
@@ -0,0 +1,56 @@
+package lib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// words returns n distinct words, starting with word number from.
+func words(from, n int) []string {
+	w := make([]string, n)
+	for i := range w {
+		w[i] = fmt.Sprintf("w%d", from+i)
+	}
+	return w
+}
+
+// writeTSV writes "id\ttext" lines to a file in dir and returns its name.
+func writeTSV(t *testing.T, dir, name string, rows ...string) string {
+	filename := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(filename, []byte(strings.Join(rows, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestCross(t *testing.T) {
+	tsv := DelimitedReader{Comma: '\t', IdCol: "0", TextCol: "1"}
+	dir := t.TempDir()
+	text := words(0, 40)
+	near := append([]string(nil), text...)
+	near[20] = "changed"
+	// Half of partial is the text, which a low threshold LSH index finds
+	// but which is well below the threshold.
+	partial := append(append([]string(nil), text[:13]...), words(100, 27)...)
+
+	index := writeTSV(t, dir, "index.tsv",
+		"a\t"+strings.Join(text, " "),
+		"b\t"+strings.Join(words(200, 40), " "))
+	query := writeTSV(t, dir, "query.tsv",
+		"q1\t"+strings.Join(near, " "),
+		"q2\t"+strings.Join(partial, " "))
+
+	dd := MakeDeduper(MakeLSH(128, 64), *NewMinhash(128), tsv.Read)
+	dd.Normalizer = Normalizer{}
+	dd.Shingler.Size = 3
+	var got []string
+	dd.cross([]string{index}, []string{query}, tsv.Read, Normalizer{}, 0.6, func(q, d string, sim float64) {
+		got = append(got, q+" "+d)
+	})
+	if strings.Join(got, ",") != "q1 a" {
+		t.Errorf("Got matches %v, want only q1 a", got)
+	}
+}
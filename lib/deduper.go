@@ -2,15 +2,93 @@ package lib
 
 import (
 	"bufio"
+	"crypto/sha256"
 	"os"
 	"log"
 	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
 )
 
 type Deduper struct {
 	lsh LSH
 	minhash MinHasher
 	readfn func(*bufio.Reader, chan Document)
+
+	// Normalizer cleans up document text before anything else is done
+	// with it.  MakeDeduper starts it with the "legacy" profile.
+	Normalizer Normalizer
+	// Shingler sets how documents are broken into shingles.
+	Shingler Shingler
+
+	// Exact groups documents with identical text by a hash of the text
+	// before shingling, so that only one document per group goes through
+	// minhash and LSH.
+	Exact bool
+	// ExactFile, if set, is where the exact-duplicate groups are written,
+	// one "representative docid" pair per line.
+	ExactFile string
+	// repeats holds the ordinals of records that repeat both the id and
+	// the text of an earlier record, which are skipped after the first
+	// pass.
+	repeats map[int]bool
+
+	// MaxDF, if set, drops shingles that occur in more than this many
+	// documents, or this fraction of documents if it is less than one.
+	MaxDF float64
+	// Stopwords, if set, drops word shingles that begin with a stopword.
+	Stopwords []string
+	// FilterReport, if set, is where the shingles dropped for their
+	// document frequency are written, one "df shingle" pair per line.
+	FilterReport string
+	filter       *shingleFilter
+
+	// EmitFile, if set, is where the raw records of one document per
+	// cluster are written, making a deduplicated collection.
+	EmitFile string
+	// Annotate adds "cluster" and "cluster_size" fields to emitted
+	// records that are JSON objects.
+	Annotate bool
+
+	// Representative says which document in each cluster represents it
+	// in the output and the deduplicated collection.
+	Representative RepPolicy
+	// Sources ranks document sources, most preferred first, for the
+	// SOURCE policy.
+	Sources []string
+
+	// PairsFile, if set, is where each candidate pair that LSH puts in a
+	// cluster is written, with its similarity and the bands it collided
+	// in, as "jsonl" or "tsv" according to PairsFormat.  Exact
+	// duplicates are not written here; see ExactFile.  Pairs are written
+	// before any clusters are split.
+	PairsFile   string
+	PairsFormat string
+	// PairsExact adds the exact Jaccard similarity of the shingle sets to
+	// each pair, which means keeping every document's shingles in memory.
+	PairsExact bool
+
+	// MaxDiameter, if set, is the largest distance, one minus the
+	// estimated Jaccard similarity, allowed between two documents in a
+	// cluster.  Clusters that chain together documents further apart than
+	// this are split using SplitMethod.  Only COMPLETE keeps to this for
+	// every pair; CENTER, which is also used for clusters over
+	// COMPLETE_LINK_MAX, keeps it as the distance from each document to
+	// its cluster's first.
+	MaxDiameter float64
+	SplitMethod SplitMethod
+
+	// Thresholds, if set, are Jaccard similarity thresholds to cluster at
+	// in one run, giving nested single-link clusters rather than the
+	// clusters Dedupe makes otherwise; see dedupeLevels.  The LSH index
+	// should be made for the lowest.
+	Thresholds []float64
+
+	// StatsFile, if set, is where the statistics printed at the end of
+	// Dedupe are also written, as JSON, or as a JSON array with an entry
+	// for each of the Thresholds.
+	StatsFile string
 }
 
 func MakeDeduper(lsh LSH, minhash MinHasher, readfn func(*bufio.Reader, chan Document)) *Deduper {
@@ -18,6 +96,8 @@ func MakeDeduper(lsh LSH, minhash MinHasher, readfn func(*bufio.Reader, chan Doc
 	dd.lsh = lsh
 	dd.minhash = minhash
 	dd.readfn = readfn
+	dd.Normalizer, _ = MakeNormalizer(NormalizeProfiles["legacy"])
+	dd.Shingler = Shingler{Size: SHINGLE_LEN, Unit: WORD, Tokenizer: DefaultTokenizer}
 	return dd
 }
 
@@ -33,65 +113,440 @@ func (dd Deduper) Query(prints []uint32) []string {
 	return dd.lsh.Query(prints)
 }
 
-func (dd Deduper) Dedupe(filename string) {
-	file, err := os.Open(filename)
-	if err != nil {
-		log.Fatal(err)
+// scan runs the reader over each file in turn and calls fn on each
+// document after normalizing its text, logging progress as it goes.
+func (dd Deduper) scan(filenames []string, fn func(Document)) int {
+	return dd.read(filenames, func(doc Document) {
+		doc.Text = dd.Normalizer.Normalize(doc.Text)
+		fn(doc)
+	})
+}
+
+// read runs the reader over each file in turn and calls fn on each
+// document as it was read.
+func (dd Deduper) read(filenames []string, fn func(Document)) int {
+	return dd.records(filenames, func(doc Document) {
+		if doc.Kind == DOCUMENT {
+			fn(doc)
+		}
+	})
+}
+
+// records is like read, but also calls fn on the headers and footers
+// around the documents.  It returns the number of documents.
+func (dd Deduper) records(filenames []string, fn func(Document)) int {
+	ordinal, doccount := 0, 0
+	for _, filename := range filenames {
+		file, err := openFile(filename)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		reader := bufio.NewReader(file)
+		doc_chan := make(chan Document)
+		go dd.readfn(reader, doc_chan)
+
+		for doc := range doc_chan {
+			if doc.Kind != DOCUMENT {
+				fn(doc)
+				continue
+			}
+			ordinal++
+			if dd.repeats[ordinal] {
+				continue
+			}
+			doccount++
+			if doc.Source == "" {
+				doc.Source = filepath.Base(filename)
+			}
+			if (doccount % 10000) == 0 {
+				log.Println(doccount, "docs")
+			}
+			fn(doc)
+		}
+		file.Close()
 	}
-	defer file.Close()
+	return doccount
+}
 
+// indexAll is the first pass of Dedupe.  It indexes the signature of each
+// document, leaving out exact duplicates if Exact is set, and returns a map
+// from each exact duplicate to the first document with the same text,
+// along with the number of documents read.  A record with the id and text
+// of an earlier one, such as a second copy of a mail message, is the same
+// document read twice, and is left out of the count and, through
+// dd.repeats, the later passes.  Signatures and shingles are also kept in
+// allsigs and allshingles if they aren't nil.
+func (dd Deduper) indexAll(filenames []string, allsigs, allshingles map[string][]uint32) (map[string]string, int) {
 	log.Println("--- First pass, indexing documents")
-		
-	reader := bufio.NewReader(file)
-	doccount := 0
 
-	doc_chan := make(chan Document)
-	go dd.readfn(reader, doc_chan)
+	// exact maps text hashes to the first document with that text, and
+	// id2exact maps later documents with the same text to that first one.
+	exact := make(map[[sha256.Size]byte]string)
+	id2exact := make(map[string]string)
 
-	for doc := range doc_chan {
-		doccount++
-		if (doccount % 10000) == 0 {
-			log.Println(doccount, "docs")
+	ordinal := 0
+	doccount := dd.scan(filenames, func(doc Document) {
+		ordinal++
+		if dd.Exact {
+			h := sha256.Sum256([]byte(doc.Text))
+			if rep, ok := exact[h]; ok {
+				if rep == doc.Id || id2exact[doc.Id] == rep {
+					dd.repeats[ordinal] = true
+					return
+				}
+				id2exact[doc.Id] = rep
+				return
+			}
+			exact[h] = doc.Id
 		}
 
-		sigs := Shingle(doc.Text)
-		sigs = dd.Fingerprint(sigs)
+		shingles := dd.shingle(doc.Text)
+		sigs := dd.Fingerprint(shingles)
 		dd.Index(doc.Id, sigs)
+		if allsigs != nil {
+			allsigs[doc.Id] = sigs
+		}
+		if allshingles != nil {
+			allshingles[doc.Id] = shingles
+		}
+	})
+	dd.filter.closeReport()
+	if dd.Exact {
+		log.Println(len(id2exact), "exact duplicates")
+	}
+	if len(dd.repeats) > 0 {
+		log.Println(len(dd.repeats), "repeated records skipped")
+	}
+	return id2exact, doccount - len(dd.repeats)
+}
+
+// printCluster prints the cluster line for a document.  The docid of an
+// exact duplicate is marked with a leading "=", so that exact-duplicate
+// groups can be told apart from near-duplicate clusters.
+func printCluster(cluster, id, name string, exact bool) {
+	if exact {
+		id = "=" + id
+	}
+	fmt.Println(cluster, id, name)
+}
+
+// Dedupe clusters the documents in the files, which are read in order as
+// one collection, and prints a "cluster docid name" line for each one.
+// The cluster is named by its representative, and exact duplicates are
+// marked as "cluster =docid name".  At the end it prints
+// statistics about the clusters and how long each phase took.
+//
+// If Thresholds are set, the documents are clustered at each one instead;
+// see dedupeLevels.
+func (dd Deduper) Dedupe(filenames ...string) {
+	stats := new(Stats)
+	start := time.Now()
+	dd.filter = dd.makeFilter(filenames)
+	dd.repeats = make(map[int]bool)
+	if dd.MaxDF > 0 {
+		stats.timePhase("filter", start)
+	}
+	if len(dd.Thresholds) > 0 {
+		dd.dedupeLevels(filenames, stats.Phases)
+		return
+	}
+	start = time.Now()
+
+	// Writing pairs and splitting clusters need the signature of every
+	// document, and exact Jaccard needs its shingles too.
+	var allsigs, allshingles map[string][]uint32
+	if dd.PairsFile != "" || dd.MaxDiameter > 0 {
+		allsigs = make(map[string][]uint32)
+	}
+	if dd.PairsFile != "" && dd.PairsExact {
+		allshingles = make(map[string][]uint32)
+	}
+	id2exact, doccount := dd.indexAll(filenames, allsigs, allshingles)
+	stats.ExactDuplicates = len(id2exact)
+	stats.timePhase("index", start)
+
+	var exactOut *bufio.Writer
+	if dd.ExactFile != "" {
+		file, err := os.Create(dd.ExactFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		exactOut = bufio.NewWriter(file)
+		defer exactOut.Flush()
+	}
+
+	pairs := dd.openPairs()
+	if pairs != nil {
+		defer pairs.close()
 	}
 
 	log.Println("--- Second pass, identifying duplicates")
-		
-	file.Seek(0, 0)
-	reader = bufio.NewReader(file)
+	start = time.Now()
+
 	id2cluster := make(map[string]string, doccount)
-	doccount = 0
-
-	doc_chan = make(chan Document)
-	go dd.readfn(reader, doc_chan)
-	
-	for doc := range doc_chan {
-		doccount++
-		if (doccount % 10000) == 0 {
-			log.Println(doccount, "docs")
-		}
-		
-		cluster, ok := id2cluster[doc.Id]
-		if ok {
-			fmt.Println(cluster, doc.Id, doc.Name)
-			continue
+
+	// Unless representatives are chosen or clusters are split after this
+	// pass, each document's cluster is settled by the time it is read, so
+	// clusters are printed and representatives written as we go.  The
+	// names of the first few members of each cluster with more than one
+	// are kept for the statistics.
+	direct := dd.Representative == FIRST && dd.MaxDiameter == 0
+	var out *emitter
+	var titles map[string][]string
+	exactReps := make(map[string]bool)
+	if direct {
+		if dd.EmitFile != "" && !dd.Annotate {
+			out = dd.openEmit()
+			log.Println("Writing deduplicated collection to", dd.EmitFile)
+		}
+		titles = make(map[string][]string)
+		for _, rep := range id2exact {
+			exactReps[rep] = true
+		}
+	}
+	claimed := 0
+
+	// info is only kept if the representative policy needs it.
+	var info map[string]docInfo
+	if dd.Representative != FIRST {
+		info = make(map[string]docInfo, doccount)
+	}
+	order := 0
+	keep := func(doc Document, sigs []uint32) {
+		if info == nil {
+			return
+		}
+		i := docInfo{order: order, length: len(doc.Text), source: doc.Source}
+		if !doc.Date.IsZero() {
+			i.date, i.dated = doc.Date.Unix(), true
+		}
+		if dd.Representative == CENTROID {
+			i.sigs = sigs
+		}
+		info[doc.Id] = i
+		order++
+	}
+
+	assign := func(doc Document) {
+		// An exact duplicate goes wherever its representative went,
+		// which is already settled since the representative came first.
+		if rep, ok := id2exact[doc.Id]; ok {
+			if exactOut != nil {
+				fmt.Fprintln(exactOut, rep, doc.Id)
+			}
+			id2cluster[doc.Id] = id2cluster[rep]
+			keep(doc, info[rep].sigs)
+			return
 		}
-		
+
+		var shingles, sigs []uint32
+		if _, ok := id2cluster[doc.Id]; !ok || dd.Representative == CENTROID {
+			shingles = dd.shingle(doc.Text)
+			sigs = dd.Fingerprint(shingles)
+		}
+		keep(doc, sigs)
+		if _, ok := id2cluster[doc.Id]; ok {
+			return
+		}
+
 		id2cluster[doc.Id] = doc.Id
-		fmt.Println(doc.Id, doc.Id, doc.Name)
-		
-		sigs := Shingle(doc.Text)
-		sigs = dd.Fingerprint(sigs)
-		dupes := dd.Query(sigs)
-		for _, d := range dupes {
-			if _, ok := id2cluster[d]; !ok {
-				id2cluster[d] = doc.Id
+		// The bands each candidate collided in are only needed for the
+		// pairs file.
+		candidates := dd.lsh.QueryBands(sigs)
+		keys := make([]string, 0, len(candidates))
+		for d := range candidates {
+			keys = append(keys, d)
+		}
+		sort.Strings(keys)
+		for _, d := range keys {
+			if _, ok := id2cluster[d]; ok {
+				continue
+			}
+			id2cluster[d] = doc.Id
+			claimed++
+			if pairs == nil {
+				continue
+			}
+			p := Pair{A: doc.Id, B: d, Estimate: EstimateJaccard(sigs, allsigs[d]),
+				NumBands: len(candidates[d]), Bands: candidates[d]}
+			if allshingles != nil {
+				j := ExactJaccard(shingles, allshingles[d])
+				p.Exact = &j
+			}
+			pairs.write(p)
+		}
+	}
+
+	// members lists the documents of each cluster in input order, for
+	// splitting.
+	var members map[string][]string
+	if dd.MaxDiameter > 0 {
+		members = make(map[string][]string)
+	}
+	dd.records(filenames, func(doc Document) {
+		if doc.Kind != DOCUMENT {
+			if out != nil {
+				out.frame(doc)
+			}
+			return
+		}
+		doc.Text = dd.Normalizer.Normalize(doc.Text)
+		claimed = 0
+		assign(doc)
+		cluster := id2cluster[doc.Id]
+		if members != nil {
+			members[cluster] = append(members[cluster], doc.Id)
+		}
+		if !direct {
+			return
+		}
+		_, exact := id2exact[doc.Id]
+		printCluster(cluster, doc.Id, doc.Name, exact)
+		if (cluster != doc.Id || claimed > 0 || exactReps[doc.Id]) && len(titles[cluster]) < STATS_TITLES {
+			titles[cluster] = append(titles[cluster], doc.Name)
+		}
+		if out != nil && cluster == doc.Id {
+			out.write(doc, cluster, 0)
+		}
+	})
+	if out != nil {
+		out.close()
+	}
+	exactReps = nil
+	stats.timePhase("cluster", start)
+
+	if members != nil {
+		start = time.Now()
+		dd.splitClusters(members, func(id string) []uint32 {
+			if sigs, ok := allsigs[id]; ok {
+				return sigs
+			}
+			return allsigs[id2exact[id]]
+		}, id2cluster)
+		members = nil
+		stats.timePhase("split", start)
+	}
+
+	var reps map[string]string
+	if info != nil {
+		start = time.Now()
+		reps = dd.chooseReps(id2cluster, info)
+		info = nil
+		stats.timePhase("representatives", start)
+	}
+
+	stats.count(doccount, id2cluster, id2exact, reps)
+	for cluster, names := range titles {
+		for _, name := range names {
+			stats.sample(cluster, name)
+		}
+	}
+	// Annotating emitted records needs the final cluster sizes.
+	if !direct || (dd.EmitFile != "" && dd.Annotate) {
+		start = time.Now()
+		dd.output(filenames, id2cluster, id2exact, reps, stats, !direct)
+		stats.timePhase("output", start)
+	}
+
+	stats.Print(os.Stderr)
+	if dd.StatsFile != "" {
+		if err := stats.WriteJSON(dd.StatsFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// Lookup prints the documents in the file that the LSH index finds similar
+// to each of the documents named in ids.
+func (dd Deduper) Lookup(filename string, ids []string) {
+	queries := dd.indexQueries(filename, ids, func(key string, sigs []uint32, size int) {
+		dd.Index(key, sigs)
+	})
+	for _, id := range ids {
+		q, ok := queries[id]
+		if !ok {
+			log.Println("Document", id, "not found")
+			continue
+		}
+		for _, d := range dd.Query(q.sigs) {
+			if d != id {
+				fmt.Println(id, d)
+			}
+		}
+	}
+}
+
+// LookupTopK prints the k documents in the file most similar to each of
+// the documents named in ids, ranked by estimated Jaccard similarity.
+func (dd Deduper) LookupTopK(filename string, ids []string, forest *LSHForest, k int) {
+	queries := dd.indexQueries(filename, ids, func(key string, sigs []uint32, size int) {
+		forest.Insert(key, sigs)
+	})
+	for _, id := range ids {
+		q, ok := queries[id]
+		if !ok {
+			log.Println("Document", id, "not found")
+			continue
+		}
+		// Ask for one extra, since the query document will find itself.
+		rank := 0
+		for _, m := range forest.Query(q.sigs, k+1) {
+			if m.Id == id || rank == k {
+				continue
 			}
+			rank++
+			fmt.Printf("%s %d %s %.4f\n", id, rank, m.Id, m.Jaccard)
 		}
 	}
+}
+
+// LookupContained prints the documents in the file estimated to contain at
+// least the threshold fraction of each of the documents named in ids, along
+// with the estimated containment.
+func (dd Deduper) LookupContained(filename string, ids []string, ensemble *LSHEnsemble, threshold float64) {
+	queries := dd.indexQueries(filename, ids, ensemble.Insert)
+	for _, id := range ids {
+		q, ok := queries[id]
+		if !ok {
+			log.Println("Document", id, "not found")
+			continue
+		}
+		for _, d := range ensemble.Query(q.sigs, q.size, threshold) {
+			if d != id {
+				fmt.Printf("%s %s %.4f\n", id, d,
+					EstimateContainment(q.sigs, ensemble.sigs[d], q.size, ensemble.sizes[d]))
+			}
+		}
+	}
+}
+
+// queryPrint is the fingerprint of a query document, along with the number
+// of shingles it was computed from.
+type queryPrint struct {
+	sigs []uint32
+	size int
+}
 
+// indexQueries fingerprints every document in the file and passes it to
+// insert, keeping the fingerprints of the documents named in ids.
+func (dd Deduper) indexQueries(filename string, ids []string, insert func(string, []uint32, int)) map[string]queryPrint {
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	queries := make(map[string]queryPrint, len(ids))
+
+	log.Println("--- Indexing documents")
+
+	dd.scan([]string{filename}, func(doc Document) {
+		shingles := dd.shingle(doc.Text)
+		sigs := dd.Fingerprint(shingles)
+		insert(doc.Id, sigs, len(shingles))
+		if wanted[doc.Id] {
+			queries[doc.Id] = queryPrint{sigs, len(shingles)}
+		}
+	})
+	return queries
 }
@@ -0,0 +1,82 @@
+package lib
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureStdout returns what fn prints to standard output.
+func captureStdout(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		b, _ := ioutil.ReadAll(r)
+		done <- b
+	}()
+	fn()
+	os.Stdout = stdout
+	w.Close()
+	return string(<-done)
+}
+
+func TestDedupeExact(t *testing.T) {
+	tsv := DelimitedReader{Comma: '\t', IdCol: "0", TextCol: "1"}
+	dir := t.TempDir()
+	text := strings.Join(words(0, 40), " ")
+	// The second a is the same record read again, as happens with two
+	// copies of one mail message.
+	input := writeTSV(t, dir, "in.tsv",
+		"a\t"+text,
+		"b\t"+text,
+		"a\t"+text,
+		"c\t"+strings.Join(words(100, 40), " "))
+
+	dd := MakeDeduper(MakeLSH(128, 32), *NewMinhash(128), tsv.Read)
+	dd.Normalizer = Normalizer{}
+	dd.Exact = true
+	dd.ExactFile = filepath.Join(dir, "exact.txt")
+	dd.EmitFile = filepath.Join(dir, "emit.tsv")
+	dd.StatsFile = filepath.Join(dir, "stats.json")
+	out := captureStdout(t, func() { dd.Dedupe(input) })
+
+	var printed []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		printed = append(printed, strings.Join(strings.Fields(line)[:2], " "))
+	}
+	if got, want := strings.Join(printed, ","), "a a,a =b,c c"; got != want {
+		t.Errorf("Printed clusters %s, want %s", got, want)
+	}
+	if b, err := ioutil.ReadFile(dd.ExactFile); err != nil || string(b) != "a b\n" {
+		t.Errorf("Exact file is %q, %v, want %q", b, err, "a b\n")
+	}
+	b, err := ioutil.ReadFile(dd.EmitFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(b)), "\n"); len(lines) != 2 ||
+		!strings.HasPrefix(lines[0], "a\t") || !strings.HasPrefix(lines[1], "c\t") {
+		t.Errorf("Emitted %q, want a and c once each", b)
+	}
+
+	b, err = ioutil.ReadFile(dd.StatsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats Stats
+	if err := json.Unmarshal(b, &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Documents != 3 || stats.ExactDuplicates != 1 || stats.Clusters != 2 ||
+		len(stats.Largest) != 1 || stats.Largest[0].Size != 2 {
+		t.Errorf("Got stats %+v, want 3 documents, 1 exact duplicate, and clusters of 2 and 1", stats)
+	}
+}
@@ -0,0 +1,173 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// A DelimitedReader reads documents from CSV or TSV files, one per row,
+// such as the MS MARCO v1 passages (pid\tpassage).
+//
+// Columns are given either as zero-based indices or, when the file has a
+// Header row, as column names.  If there is no title column, the start of
+// the text is used as the name of the document.  If there is a date
+// column, dates are parsed with the time.Parse layout DateLayout.
+//
+// With Quotes, fields may be quoted as in RFC 4180, and quoted fields can
+// hold delimiters and newlines.  Without it, every line is a row and quote
+// characters are just text, which is what most TSV files expect.
+//
+// With ExtractHTML, the text column is taken to hold web pages, and its
+// main text is pulled out with ExtractText.
+type DelimitedReader struct {
+	Comma       rune
+	Header      bool
+	Quotes      bool
+	IdCol       string
+	TextCol     string
+	TitleCol    string
+	DateCol     string
+	DateLayout  string
+	ExtractHTML bool
+}
+
+// columns works out the indices of the id, text, title and date columns.
+// The title and date indices are -1 if there are no such columns.
+func (d DelimitedReader) columns(header []string) (id, text, title, date int, err error) {
+	find := func(col string) (int, error) {
+		if i, err := strconv.Atoi(col); err == nil {
+			if i < 0 {
+				return -1, fmt.Errorf("column %d is negative", i)
+			}
+			return i, nil
+		}
+		for i, name := range header {
+			if strings.TrimSpace(name) == col {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("no column %q", col)
+	}
+	if id, err = find(d.IdCol); err != nil {
+		return
+	}
+	if text, err = find(d.TextCol); err != nil {
+		return
+	}
+	title, date = -1, -1
+	if d.TitleCol != "" {
+		if title, err = find(d.TitleCol); err != nil {
+			return
+		}
+	}
+	if d.DateCol != "" {
+		date, err = find(d.DateCol)
+	}
+	return
+}
+
+// Read sends a document for each row in the stream to the channel, and
+// closes the channel at the end.
+func (d DelimitedReader) Read(reader *bufio.Reader, c chan Document) {
+	defer close(c)
+
+	// next returns the fields of the next row and its raw record, the
+	// bytes of the row exactly as they are in the input.
+	var next func() ([]string, string, error)
+	if d.Quotes {
+		next = func() ([]string, string, error) {
+			for {
+				raw, err := d.quotedRecord(reader)
+				if raw == "" {
+					return nil, "", err
+				}
+				r := csv.NewReader(strings.NewReader(raw))
+				r.Comma = d.Comma
+				r.LazyQuotes = true
+				row, err := r.Read()
+				if err == io.EOF {
+					// A blank line.
+					continue
+				}
+				return row, raw, err
+			}
+		}
+	} else {
+		sep := string(d.Comma)
+		next = func() ([]string, string, error) {
+			line, err := reader.ReadString('\n')
+			if line == "" && err != nil {
+				return nil, "", err
+			}
+			return strings.Split(strings.TrimRight(line, "\r\n"), sep), line, nil
+		}
+	}
+
+	var header []string
+	if d.Header {
+		row, raw, err := next()
+		if err != nil {
+			log.Println("Error reading header:", err)
+			return
+		}
+		header = append(header, row...)
+		c <- Document{Raw: raw, Kind: HEADER}
+	}
+	id, text, title, date, err := d.columns(header)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for {
+		row, raw, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Println(err)
+			if _, ok := err.(*csv.ParseError); ok {
+				continue
+			}
+			break
+		}
+		if id >= len(row) || text >= len(row) || title >= len(row) || date >= len(row) {
+			log.Println("Skipping short row", row)
+			continue
+		}
+		var name string
+		if title >= 0 {
+			name = row[title]
+		}
+		body := row[text]
+		if d.ExtractHTML {
+			body = ExtractText(body)
+		}
+		doc := Document{Text: body, Id: row[id], Name: documentName(name, body), Raw: raw}
+		if date >= 0 {
+			doc.Date = parseDate(row[date], d.DateLayout)
+		}
+		c <- doc
+	}
+}
+
+// quotedRecord reads the lines of the next row, which goes on past the end
+// of a line while a quoted field is open.  Quotes in quoted fields are
+// doubled, so a field is open while there is an odd number of quotes.
+func (d DelimitedReader) quotedRecord(reader *bufio.Reader) (string, error) {
+	var raw strings.Builder
+	quotes := 0
+	for {
+		line, err := reader.ReadString('\n')
+		raw.WriteString(line)
+		quotes += strings.Count(line, `"`)
+		if err != nil || quotes%2 == 0 {
+			return raw.String(), err
+		}
+	}
+}
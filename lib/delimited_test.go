@@ -0,0 +1,80 @@
+package lib
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func readDelimited(d DelimitedReader, input string) []Document {
+	c := make(chan Document)
+	go d.Read(bufio.NewReader(strings.NewReader(input)), c)
+	var docs []Document
+	for doc := range c {
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+func TestDelimitedTSV(t *testing.T) {
+	input := "0\tThe \"quick\" brown fox.\n1\t\"Quoted\" start, no end\n"
+	docs := readDelimited(DelimitedReader{Comma: '\t', IdCol: "0", TextCol: "1"}, input)
+	if len(docs) != 2 {
+		t.Fatalf("Expected 2 documents, got %d", len(docs))
+	}
+	if docs[0].Text != "The \"quick\" brown fox." || docs[1].Text != "\"Quoted\" start, no end" {
+		t.Errorf("Quotes should be kept as text, got %q and %q", docs[0].Text, docs[1].Text)
+	}
+}
+
+func TestDelimitedCSVHeader(t *testing.T) {
+	row := "\"Storm, again\",d1,\"Line one,\r\nline \"\"two\"\"\"\r\n"
+	input := "title,id,body\r\n" + row + "\r\nCalm,d2,Nothing happened\r\n"
+	d := DelimitedReader{Comma: ',', Header: true, Quotes: true, IdCol: "id", TextCol: "body", TitleCol: "title"}
+	docs := readDelimited(d, input)
+	if len(docs) != 3 {
+		t.Fatalf("Expected a header and 2 documents, got %d", len(docs))
+	}
+	if docs[0].Kind != HEADER || docs[0].Raw != "title,id,body\r\n" {
+		t.Errorf("Bad header %+v", docs[0])
+	}
+	docs = docs[1:]
+	if docs[0].Id != "d1" || docs[0].Name != "Storm, again" || docs[0].Text != "Line one,\nline \"two\"" {
+		t.Errorf("Bad quoted row %+v", docs[0])
+	}
+	if docs[0].Raw != row {
+		t.Errorf("Raw row %q, want the input %q", docs[0].Raw, row)
+	}
+	if docs[1].Id != "d2" || docs[1].Text != "Nothing happened" {
+		t.Errorf("Bad second row %+v", docs[1])
+	}
+}
+
+func TestDelimitedNegativeColumn(t *testing.T) {
+	docs := readDelimited(DelimitedReader{Comma: '\t', IdCol: "-1", TextCol: "1"}, "0\tSome text.\n")
+	if len(docs) != 0 {
+		t.Errorf("Read %d documents with a negative column", len(docs))
+	}
+}
+
+func TestDelimitedDate(t *testing.T) {
+	d := DelimitedReader{Comma: '\t', IdCol: "0", TextCol: "1", DateCol: "2", DateLayout: "2006-01-02"}
+	docs := readDelimited(d, "a\tSome text.\t1970-01-01\nb\tMore text.\tyesterday\n")
+	if len(docs) != 2 || docs[0].Date.IsZero() || docs[0].Date.Unix() != 0 || !docs[1].Date.IsZero() {
+		t.Errorf("Bad dates %+v", docs)
+	}
+}
+
+func TestDelimitedExtractHTML(t *testing.T) {
+	input := "0\t<nav>Home | About</nav><p>The main story of the page is here.</p><script>x()</script>\n"
+	docs := readDelimited(DelimitedReader{Comma: '\t', IdCol: "0", TextCol: "1", ExtractHTML: true}, input)
+	if len(docs) != 1 {
+		t.Fatalf("Expected 1 document, got %d", len(docs))
+	}
+	if got := strings.TrimSpace(docs[0].Text); got != "The main story of the page is here." {
+		t.Errorf("Got text %q, want only the paragraph", got)
+	}
+	if docs[0].Name != "The main story of the page is here." {
+		t.Errorf("Got name %q from the HTML", docs[0].Name)
+	}
+}
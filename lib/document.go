@@ -1,7 +1,72 @@
 package lib
 
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// A RecordKind says whether a record from a reader is a document, or part
+// of the input that frames the documents and is needed to write a
+// deduplicated collection in the same format, such as a CSV header row or
+// the <mediawiki> element around the pages of a wiki dump.
+type RecordKind int
+
+const (
+	DOCUMENT RecordKind = iota
+	// HEADER comes before the documents, and FOOTER after them.
+	HEADER
+	FOOTER
+)
+
 type Document struct {
 	Text string
 	Id string
 	Name string
-} 
+	// Raw is the document's record exactly as it appears in the input,
+	// so that a deduplicated collection can be written back out.
+	Raw string
+	// Date and Source, where a reader knows them, are used to choose
+	// cluster representatives.  Date is zero if it isn't known, and
+	// Source is the name of the input file if the reader doesn't set it.
+	Date time.Time
+	Source string
+	// Kind is DOCUMENT for documents.  Only Raw is set for headers and
+	// footers.
+	Kind RecordKind
+}
+
+// documentName makes a name for a document from its title, or if there is
+// no title, from the first 50 characters of its text.  White space is
+// collapsed so the name fits on one line.
+func documentName(title, text string) string {
+	if strings.TrimSpace(title) == "" {
+		title = Snippet(text, 50)
+	}
+	return strings.Join(strings.Fields(title), " ")
+}
+
+// Snippet returns the first n characters of the text, with white space
+// changed to spaces so that it fits on one line.
+func Snippet(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) > n {
+		runes = runes[:n]
+	}
+	for i, r := range runes {
+		if unicode.IsSpace(r) {
+			runes[i] = ' '
+		}
+	}
+	return string(runes)
+}
+
+// parseDate parses a date with a time.Parse layout, after trimming white
+// space, returning the zero time if it can't.
+func parseDate(value, layout string) time.Time {
+	t, err := time.Parse(layout, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,144 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// output reads the collection once more, for when the clusters weren't
+// final during the second pass of Dedupe.  If print is set, it prints a
+// "cluster docid name" line for each document with the cluster named by
+// its representative, marking exact duplicates listed in id2exact, and
+// adds sample titles of the largest clusters to the stats.  It writes the
+// raw record of each representative to EmitFile, in input order, making a
+// deduplicated collection.  reps maps cluster ids to their
+// representatives where that isn't the first document.
+func (dd Deduper) output(filenames []string, id2cluster, id2exact, reps map[string]string, stats *Stats, print bool) {
+	var out *emitter
+	sizes := make(map[string]int)
+	if dd.EmitFile != "" {
+		for _, cluster := range id2cluster {
+			sizes[cluster]++
+		}
+		out = dd.openEmit()
+		defer out.close()
+	}
+	switch {
+	case out != nil && print:
+		log.Println("--- Third pass, writing clusters and deduplicated collection to", dd.EmitFile)
+	case out != nil:
+		log.Println("--- Third pass, writing deduplicated collection to", dd.EmitFile)
+	default:
+		log.Println("--- Third pass, writing clusters")
+	}
+
+	dd.records(filenames, func(doc Document) {
+		if doc.Kind != DOCUMENT {
+			if out != nil {
+				out.frame(doc)
+			}
+			return
+		}
+		cluster := id2cluster[doc.Id]
+		rep, ok := reps[cluster]
+		if !ok {
+			rep = cluster
+		}
+		if print {
+			_, exact := id2exact[doc.Id]
+			printCluster(rep, doc.Id, doc.Name, exact)
+			stats.sample(rep, doc.Name)
+		}
+
+		if out != nil && rep == doc.Id {
+			out.write(doc, rep, sizes[cluster])
+		}
+	})
+}
+
+// An emitter writes a deduplicated collection.  The header of the first
+// input file is written before the documents, and the footer of the last
+// input file after them, so that the collection is in the same format as
+// the input.
+type emitter struct {
+	file     *os.File
+	out      *bufio.Writer
+	annotate bool
+	header   bool
+	footer   string
+	written  int
+	missing  int
+}
+
+func (dd Deduper) openEmit() *emitter {
+	file, err := os.Create(dd.EmitFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &emitter{file: file, out: bufio.NewWriter(file), annotate: dd.Annotate}
+}
+
+// frame takes note of a header or footer record.
+func (e *emitter) frame(doc Document) {
+	switch doc.Kind {
+	case HEADER:
+		if !e.header {
+			e.out.WriteString(doc.Raw)
+			e.header = true
+		}
+	case FOOTER:
+		e.footer = doc.Raw
+	}
+}
+
+// write writes the raw record of a representative document.  Records are
+// ended with a newline if they don't have one, such as TREC documents,
+// which stop at </DOC>, or the last line of a file that doesn't end with
+// a newline, so that they don't run into the next one.
+func (e *emitter) write(doc Document, cluster string, size int) {
+	if doc.Raw == "" {
+		e.missing++
+		return
+	}
+	raw := doc.Raw
+	if e.annotate {
+		raw = annotateJSON(raw, cluster, size)
+	}
+	e.out.WriteString(raw)
+	if !strings.HasSuffix(raw, "\n") {
+		e.out.WriteByte('\n')
+	}
+	e.written++
+}
+
+func (e *emitter) close() {
+	e.out.WriteString(e.footer)
+	if err := e.out.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	e.file.Close()
+	log.Println(e.written, "documents written")
+	if e.missing > 0 {
+		log.Println(e.missing, "documents had no raw record to write")
+	}
+}
+
+// annotateJSON adds cluster and cluster_size fields to a record that is a
+// JSON object, and leaves other records alone.
+func annotateJSON(raw, cluster string, size int) string {
+	body := strings.TrimRight(raw, " \t\r\n")
+	if !strings.HasPrefix(body, "{") || !strings.HasSuffix(body, "}") {
+		return raw
+	}
+	id, _ := json.Marshal(cluster)
+	fields := fmt.Sprintf(`"cluster":%s,"cluster_size":%d`, id, size)
+	inner := strings.TrimSpace(body[1 : len(body)-1])
+	if inner != "" {
+		fields = "," + fields
+	}
+	return body[:len(body)-1] + fields + "}" + raw[len(body):]
+}
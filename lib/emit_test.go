@@ -0,0 +1,119 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAnnotateJSON(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want string
+	}{
+		{`{"id": "a"}` + "\n", `{"id": "a","cluster":"c\"1","cluster_size":3}` + "\n"},
+		{"{}\r\n", `{"cluster":"c\"1","cluster_size":3}` + "\r\n"},
+		{"<DOC>\n</DOC>\n", "<DOC>\n</DOC>\n"},
+	}
+	for _, c := range cases {
+		if got := annotateJSON(c.raw, `c"1`, 3); got != c.want {
+			t.Errorf("annotateJSON(%q) = %q, want %q", c.raw, got, c.want)
+		}
+	}
+}
+
+// emitRoundTrip dedupes the files, which hold distinct documents, into an
+// emitted collection, and checks that reading it back gives the same
+// documents in the same order.
+func emitRoundTrip(t *testing.T, readfn func(*bufio.Reader, chan Document), files ...string) string {
+	dd := MakeDeduper(MakeLSH(128, 32), *NewMinhash(128), readfn)
+	dd.Normalizer = Normalizer{}
+	dd.EmitFile = filepath.Join(filepath.Dir(files[0]), "emitted")
+	var want []string
+	dd.read(files, func(doc Document) {
+		want = append(want, doc.Id+" "+strings.TrimSpace(doc.Text))
+	})
+	captureStdout(t, func() { dd.Dedupe(files...) })
+
+	var got []string
+	dd.read([]string{dd.EmitFile}, func(doc Document) {
+		got = append(got, doc.Id+" "+strings.TrimSpace(doc.Text))
+	})
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Read back %q, want %q", got, want)
+	}
+	b, err := ioutil.ReadFile(dd.EmitFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// writeFile writes the content to a file in dir and returns its name.
+func writeFile(t *testing.T, dir, name, content string) string {
+	filename := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestEmitTrec(t *testing.T) {
+	dir := t.TempDir()
+	tr := MakeTrecReader("DOC", "DOCNO", "", []string{"TEXT"})
+	a := writeFile(t, dir, "a", "<DOC><DOCNO>a</DOCNO><TEXT>"+strings.Join(words(0, 20), " ")+"</TEXT></DOC>")
+	b := writeFile(t, dir, "b", "<DOC><DOCNO>b</DOCNO><TEXT>"+strings.Join(words(100, 20), " ")+"</TEXT></DOC>")
+	if out := emitRoundTrip(t, tr.Read, a, b); strings.Contains(out, "</DOC><DOC>") {
+		t.Errorf("Emitted documents run together: %q", out)
+	}
+}
+
+func TestEmitDelimited(t *testing.T) {
+	dir := t.TempDir()
+	tsv := DelimitedReader{Comma: '\t', IdCol: "0", TextCol: "1"}
+	a := writeFile(t, dir, "a.tsv", "a\t"+strings.Join(words(0, 20), " "))
+	b := writeFile(t, dir, "b.tsv", "b\t"+strings.Join(words(100, 20), " "))
+	emitRoundTrip(t, tsv.Read, a, b)
+
+	csv := DelimitedReader{Comma: ',', Header: true, Quotes: true, IdCol: "id", TextCol: "text"}
+	a = writeFile(t, dir, "a.csv", "id,text\na,"+strings.Join(words(0, 20), " "))
+	b = writeFile(t, dir, "b.csv", "id,text\nb,\""+strings.Join(words(100, 20), " ")+"\"")
+	if out := emitRoundTrip(t, csv.Read, a, b); strings.Count(out, "id,text") != 1 {
+		t.Errorf("Emitted CSV should have one header: %q", out)
+	}
+}
+
+func TestEmitJSONL(t *testing.T) {
+	dir := t.TempDir()
+	jsonl := func(reader *bufio.Reader, c chan Document) {
+		for {
+			line, err := reader.ReadString('\n')
+			if strings.TrimSpace(line) != "" {
+				var rec struct{ Id, Text string }
+				json.Unmarshal([]byte(line), &rec)
+				c <- Document{Id: rec.Id, Text: rec.Text, Raw: line}
+			}
+			if err != nil {
+				break
+			}
+		}
+		close(c)
+	}
+	a := writeFile(t, dir, "a.jsonl", `{"id": "a", "text": "`+strings.Join(words(0, 20), " ")+`"}`)
+	b := writeFile(t, dir, "b.jsonl", `{"id": "b", "text": "`+strings.Join(words(100, 20), " ")+`"}`)
+	emitRoundTrip(t, jsonl, a, b)
+}
+
+func TestEmitMail(t *testing.T) {
+	dir := t.TempDir()
+	mr := MailReader{}
+	a := writeFile(t, dir, "1", "Message-ID: <a@x>\nSubject: one\n\n"+strings.Join(words(0, 20), " ")+"\nFrom here on, more.\n")
+	b := writeFile(t, dir, "2", "Message-ID: <b@x>\nSubject: two\n\n"+strings.Join(words(100, 20), " "))
+	out := emitRoundTrip(t, mr.Read, a, b)
+	if strings.Count(out, "\nFrom MAILER-DAEMON ") != 1 || !strings.HasPrefix(out, "From MAILER-DAEMON ") {
+		t.Errorf("Emitted messages aren't an mbox: %q", out)
+	}
+}
@@ -0,0 +1,167 @@
+package lib
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// EnglishStopwords is a short list of English function words, for use with
+// Deduper.Stopwords.
+var EnglishStopwords = []string{
+	"a", "about", "after", "all", "also", "an", "and", "any", "are", "as",
+	"at", "be", "been", "but", "by", "can", "could", "did", "do", "does",
+	"for", "from", "had", "has", "have", "he", "her", "him", "his", "how",
+	"i", "if", "in", "into", "is", "it", "its", "just", "me", "more",
+	"most", "my", "no", "not", "of", "on", "one", "only", "or", "other",
+	"our", "out", "over", "she", "so", "some", "such", "than", "that",
+	"the", "their", "them", "then", "there", "these", "they", "this",
+	"those", "to", "up", "us", "was", "we", "were", "what", "when",
+	"which", "who", "will", "with", "would", "you", "your",
+}
+
+// A shingleFilter drops shingles before they are minhashed: those whose
+// document frequency is over a cutoff, which are mostly boilerplate like
+// bylines and newsletter signups, and optionally those that begin with a
+// stopword.
+type shingleFilter struct {
+	df        map[uint32]int
+	stopwords map[string]bool
+	file      *os.File
+	report    *bufio.Writer
+	reported  map[uint32]bool
+}
+
+// shingle returns the distinct fingerprints of the shingles in the text,
+// less any the filter drops.
+func (dd Deduper) shingle(text string) []uint32 {
+	if dd.filter == nil {
+		return dd.Shingler.Shingle(text)
+	}
+	f := dd.filter
+	shingles := dd.Shingler.Strings(text)
+	resmap := make(map[uint32]bool, len(shingles))
+	for _, shingle := range shingles {
+		if f.stopwords != nil {
+			word := shingle
+			if i := strings.IndexByte(shingle, ' '); i >= 0 {
+				word = shingle[:i]
+			}
+			if f.stopwords[word] {
+				continue
+			}
+		}
+		fp := fingerprint(shingle)
+		if df, ok := f.df[fp]; ok {
+			if f.report != nil && !f.reported[fp] {
+				fmt.Fprintf(f.report, "%d\t%s\n", df, shingle)
+				f.reported[fp] = true
+			}
+			continue
+		}
+		resmap[fp] = true
+	}
+	// A document made entirely of boilerplate would otherwise get an empty
+	// signature and match every other such document.
+	if len(resmap) == 0 {
+		return dd.Shingler.Shingle(text)
+	}
+	result := make([]uint32, 0, len(resmap))
+	for fp := range resmap {
+		result = append(result, fp)
+	}
+	return result
+}
+
+// makeFilter sets up shingle filtering for the collection in the files.  If
+// MaxDF is set, this takes a pass over the collection to count shingle
+// document frequencies.  Documents with the same text are only counted
+// once, so that exact duplicates don't lose their shingles.
+func (dd Deduper) makeFilter(filenames []string) *shingleFilter {
+	// Stopwords are words, so they only make sense for word shingles.
+	stopwords := dd.Stopwords
+	if len(stopwords) > 0 && dd.Shingler.Unit != WORD {
+		log.Printf("Not filtering stopwords from %s shingles\n", dd.Shingler.Unit)
+		stopwords = nil
+	}
+	if dd.MaxDF <= 0 && len(stopwords) == 0 {
+		return nil
+	}
+	f := new(shingleFilter)
+	f.df = make(map[uint32]int)
+	if len(stopwords) > 0 {
+		f.stopwords = make(map[string]bool, len(stopwords))
+		for _, w := range stopwords {
+			f.stopwords[w] = true
+		}
+	}
+	if dd.MaxDF <= 0 {
+		return f
+	}
+
+	log.Println("--- Counting shingle document frequencies")
+
+	counts := make(map[uint32]int)
+	seen := make(map[[sha256.Size]byte]bool)
+	doccount := 0
+	dd.scan(filenames, func(doc Document) {
+		h := sha256.Sum256([]byte(doc.Text))
+		if seen[h] {
+			return
+		}
+		seen[h] = true
+		doccount++
+		for _, fp := range dd.Shingler.Shingle(doc.Text) {
+			counts[fp]++
+		}
+	})
+
+	cutoff := dfCutoff(dd.MaxDF, doccount)
+	for fp, df := range counts {
+		if df > cutoff {
+			f.df[fp] = df
+		}
+	}
+	log.Printf("Dropping %d shingles with document frequency over %d\n", len(f.df), cutoff)
+
+	if dd.FilterReport != "" {
+		file, err := os.Create(dd.FilterReport)
+		if err != nil {
+			log.Fatal(err)
+		}
+		f.file = file
+		f.report = bufio.NewWriter(file)
+		f.reported = make(map[uint32]bool, len(f.df))
+	}
+	return f
+}
+
+// dfCutoff is the highest document frequency kept by a MaxDF of maxDF in
+// a collection of doccount documents.
+func dfCutoff(maxDF float64, doccount int) int {
+	cutoff := int(maxDF)
+	if maxDF < 1.0 {
+		cutoff = int(maxDF * float64(doccount))
+	}
+	// A fraction of a small collection can come to less than one
+	// document, which would drop every shingle.
+	if cutoff < 1 {
+		cutoff = 1
+	}
+	return cutoff
+}
+
+// closeReport finishes the report of dropped shingles.  Every dropped
+// shingle has been seen by the end of the first pass, so later passes
+// don't need to report anything.
+func (f *shingleFilter) closeReport() {
+	if f == nil || f.report == nil {
+		return
+	}
+	f.report.Flush()
+	f.file.Close()
+	f.report = nil
+}
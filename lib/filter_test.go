@@ -0,0 +1,81 @@
+package lib
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestStopwordFilterUnits(t *testing.T) {
+	dd := MakeDeduper(MakeLSH(128, 32), *NewMinhash(128), nil)
+	dd.Stopwords = []string{"the"}
+	dd.Shingler.Size = 2
+	dd.filter = dd.makeFilter(nil)
+	if n := len(dd.shingle("the cat sat")); n != 1 {
+		t.Errorf("Got %d word shingles, want 1 after dropping stopwords", n)
+	}
+
+	dd.Shingler.Unit = CHAR
+	dd.filter = dd.makeFilter(nil)
+	if dd.filter != nil {
+		t.Errorf("Stopwords were applied to character shingles")
+	}
+}
+
+func TestMaxDFFilter(t *testing.T) {
+	tsv := DelimitedReader{Comma: '\t', IdCol: "0", TextCol: "1"}
+	dir := t.TempDir()
+	input := writeTSV(t, dir, "in.tsv",
+		"a\tred green blue subscribe now",
+		"b\tone two three subscribe now",
+		"c\tcat dog fish subscribe now")
+
+	cases := []struct {
+		maxDF float64
+		want  int
+	}{
+		{2, 3},
+		{3, 4},
+		// A third of three documents is one.
+		{0.34, 3},
+		// Less than one document still keeps shingles in just one.
+		{0.1, 3},
+	}
+	for _, c := range cases {
+		dd := MakeDeduper(MakeLSH(128, 32), *NewMinhash(128), tsv.Read)
+		dd.Normalizer = Normalizer{}
+		dd.Shingler.Size = 2
+		dd.MaxDF = c.maxDF
+		dd.filter = dd.makeFilter([]string{input})
+		if n := len(dd.shingle("red green blue subscribe now")); n != c.want {
+			t.Errorf("MaxDF %v: got %d shingles, want %d", c.maxDF, n, c.want)
+		}
+	}
+}
+
+func TestFilterReport(t *testing.T) {
+	tsv := DelimitedReader{Comma: '\t', IdCol: "0", TextCol: "1"}
+	dir := t.TempDir()
+	input := writeTSV(t, dir, "in.tsv",
+		"a\tred green blue subscribe now",
+		"b\tone two three subscribe now",
+		"c\tcat dog fish subscribe now")
+
+	dd := MakeDeduper(MakeLSH(128, 32), *NewMinhash(128), tsv.Read)
+	dd.Normalizer = Normalizer{}
+	dd.Shingler.Size = 2
+	dd.MaxDF = 2
+	dd.FilterReport = filepath.Join(dir, "report.tsv")
+	dd.filter = dd.makeFilter([]string{input})
+	dd.shingle("red green blue subscribe now")
+	dd.shingle("cat dog fish subscribe now")
+	dd.filter.closeReport()
+
+	b, err := ioutil.ReadFile(dd.FilterReport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "3\tsubscribe now\n"; string(b) != want {
+		t.Errorf("Report is %q, want %q", b, want)
+	}
+}
@@ -0,0 +1,80 @@
+package lib
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Elements whose content is never main text.  Go regexps don't have
+// backreferences, so each element gets its own pattern.
+var html_drop_res = makeElementRes("script", "style", "noscript", "template",
+	"iframe", "svg", "nav", "header", "footer", "aside", "form", "button", "select")
+
+var html_comment_re = regexp.MustCompile(`(?s)<!--.*?-->`)
+var html_main_re = regexp.MustCompile(`(?is)<(article|main)[\s>].*</(article|main)>`)
+var html_block_re = regexp.MustCompile(`(?i)</?(p|div|br|hr|li|ul|ol|dl|dt|dd|h[1-6]|table|tr|td|th|section|article|main|blockquote|pre|figure|figcaption)(\s[^>]*)?/?>`)
+var html_link_re = regexp.MustCompile(`(?is)<a[\s>].*?</a>`)
+
+func makeElementRes(names ...string) []*regexp.Regexp {
+	result := make([]*regexp.Regexp, len(names))
+	for i, name := range names {
+		result[i] = regexp.MustCompile(`(?is)<` + name + `[\s>].*?</` + name + `\s*>`)
+	}
+	return result
+}
+
+// Blocks shorter than this many words, such as menu items, share links and
+// bylines, are dropped when the page has more than one block.
+const HTML_MIN_WORDS = 5
+
+// ExtractText pulls the main text out of a web page, in the spirit of
+// Readability.  Scripts, styles, navigation, headers, footers and forms are
+// removed, and if the page marks its content with <article> or <main>, only
+// that is kept.  The rest is split into blocks at block-level tags, and
+// blocks that are mostly links or are very short are dropped.  Tags are
+// removed and entities decoded in what is left, with blocks separated by
+// newlines.
+func ExtractText(page string) string {
+	page = html_comment_re.ReplaceAllLiteralString(page, " ")
+	for _, re := range html_drop_res {
+		page = re.ReplaceAllLiteralString(page, " ")
+	}
+	if main := html_main_re.FindString(page); main != "" {
+		page = main
+	}
+
+	var blocks, texts []string
+	for _, block := range html_block_re.Split(page, -1) {
+		if text := htmlText(block); text != "" {
+			blocks = append(blocks, block)
+			texts = append(texts, text)
+		}
+	}
+	var kept []string
+	for i, block := range blocks {
+		text := texts[i]
+		words := len(strings.Fields(text))
+		if len(blocks) > 1 && words < HTML_MIN_WORDS {
+			continue
+		}
+		link_words := 0
+		for _, link := range html_link_re.FindAllString(block, -1) {
+			link_words += len(strings.Fields(htmlText(link)))
+		}
+		if float64(link_words)/float64(words) > 0.5 {
+			continue
+		}
+		kept = append(kept, text)
+	}
+	return strings.Join(kept, "\n")
+}
+
+// htmlText removes the tags from a fragment of HTML, decodes entities, and
+// collapses white space.  Block-level tags have already been split on, so
+// the tags left are inline and are removed without adding space.
+func htmlText(fragment string) string {
+	text := html_re.ReplaceAllLiteralString(fragment, "")
+	text = html.UnescapeString(text)
+	return strings.Join(strings.Fields(text), " ")
+}
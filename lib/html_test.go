@@ -0,0 +1,37 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractText(t *testing.T) {
+	page := `<html><head><title>Example</title>
+<style>p { color: red; }</style>
+<script>var x = "<p>not text</p>";</script></head>
+<body>
+<nav><ul><li><a href="/">Home</a></li><li><a href="/news">News</a></li></ul></nav>
+<div class="share"><a href="#">Share on Facebook</a> <a href="#">Tweet</a></div>
+<p>The city council voted on Tuesday to approve the new budget &amp; tax plan.</p>
+<p>Members said the plan would <a href="/x">fund schools</a> and repair roads over five years.</p>
+<p>Related: <a href="/a">Council members clash over the budget at a long meeting</a></p>
+<footer>Copyright 2020 The Example Times. All rights reserved.</footer>
+</body></html>`
+
+	got := ExtractText(page)
+	want := "The city council voted on Tuesday to approve the new budget & tax plan.\n" +
+		"Members said the plan would fund schools and repair roads over five years."
+	if got != want {
+		t.Errorf("Got %q", got)
+	}
+	if strings.Contains(got, "color") || strings.Contains(got, "not text") {
+		t.Errorf("Script or style leaked into %q", got)
+	}
+}
+
+func TestExtractTextFragment(t *testing.T) {
+	got := ExtractText(`<p>Short <b>one</b>.</p>`)
+	if got != "Short one." {
+		t.Errorf("A lone short paragraph should be kept, got %q", got)
+	}
+}
@@ -0,0 +1,188 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// unionFind groups documents into connected components.
+type unionFind map[string]string
+
+func (u unionFind) find(id string) string {
+	root := id
+	for {
+		parent, ok := u[root]
+		if !ok || parent == root {
+			break
+		}
+		root = parent
+	}
+	// Point everything on the path straight at the root.
+	for id != root {
+		next := u[id]
+		u[id] = root
+		id = next
+	}
+	return root
+}
+
+func (u unionFind) union(a, b string) {
+	ra, rb := u.find(a), u.find(b)
+	if ra != rb {
+		u[rb] = ra
+	}
+}
+
+// dedupeLevels clusters the documents at each of the Thresholds in one
+// run.  The LSH index should be built for the lowest threshold.  Each
+// candidate pair is verified once, by the Jaccard similarity estimated
+// from the signatures, and joins the clusters at every threshold it
+// reaches.
+//
+// This is not how Dedupe clusters at a single threshold, where each
+// document takes every unclaimed LSH candidate into its cluster without
+// verifying them.  Here clusters are the connected components of the
+// verified pairs, as in single-link clustering, so that a cluster at a
+// higher threshold always lies within one at a lower threshold.  The
+// clusters may be larger than Dedupe's, since chains of pairs join them.
+//
+// It prints a header line naming the columns, "#single-link@0.5" and so
+// on, and then a line for each document with its cluster at each
+// threshold, lowest first, then the docid, marked with "=" for exact
+// duplicates, and the name.  A cluster is named by its first document.
+// Exact duplicates are written to ExactFile, and statistics are printed
+// for each threshold, but representatives, pairs, splitting and emission
+// aren't used.  phases holds the time taken before clustering started.
+func (dd Deduper) dedupeLevels(filenames []string, phases []PhaseTime) {
+	thresholds := append([]float64(nil), dd.Thresholds...)
+	sort.Float64s(thresholds)
+	timer := Stats{Phases: phases}
+	start := time.Now()
+
+	allsigs := make(map[string][]uint32)
+	id2exact, doccount := dd.indexAll(filenames, allsigs, nil)
+	timer.timePhase("index", start)
+
+	log.Println("--- Verifying candidates at thresholds", thresholds)
+	start = time.Now()
+
+	levels := make([]unionFind, len(thresholds))
+	for l := range levels {
+		levels[l] = make(unionFind)
+	}
+	for id, rep := range id2exact {
+		for _, u := range levels {
+			u.union(rep, id)
+		}
+	}
+
+	// A pair is found from both ends, so candidates that have already
+	// been queried are skipped.
+	queried := make(map[string]bool, len(allsigs))
+	verified := 0
+	for id, sigs := range allsigs {
+		queried[id] = true
+		for _, d := range dd.Query(sigs) {
+			if queried[d] {
+				continue
+			}
+			verified++
+			sim := EstimateJaccard(sigs, allsigs[d])
+			for l, t := range thresholds {
+				if sim >= t {
+					levels[l].union(id, d)
+				}
+			}
+		}
+	}
+	log.Println(verified, "candidate pairs verified")
+	timer.timePhase("verify", start)
+
+	log.Println("--- Writing clusters")
+	start = time.Now()
+
+	// The statistics are counted with clusters named by their roots, and
+	// the largest are renamed by their first documents once those are
+	// known.
+	stats := make([]Stats, len(levels))
+	for l, u := range levels {
+		id2cluster := make(map[string]string, doccount)
+		for id := range allsigs {
+			id2cluster[id] = u.find(id)
+		}
+		for id := range id2exact {
+			id2cluster[id] = u.find(id)
+		}
+		stats[l].Threshold = thresholds[l]
+		stats[l].ExactDuplicates = len(id2exact)
+		stats[l].count(doccount, id2cluster, id2exact, nil)
+	}
+
+	var exactOut *bufio.Writer
+	if dd.ExactFile != "" {
+		file, err := os.Create(dd.ExactFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		exactOut = bufio.NewWriter(file)
+		defer exactOut.Flush()
+	}
+
+	// names maps the root of each cluster to its first document.
+	names := make([]map[string]string, len(levels))
+	header := make([]string, len(levels))
+	for l := range names {
+		names[l] = make(map[string]string)
+		header[l] = fmt.Sprintf("single-link@%g", thresholds[l])
+	}
+	fmt.Println("#"+strings.Join(header, " "), "docid", "name")
+	clusters := make([]string, len(levels))
+	dd.read(filenames, func(doc Document) {
+		for l, u := range levels {
+			root := u.find(doc.Id)
+			name, ok := names[l][root]
+			if !ok {
+				name = doc.Id
+				names[l][root] = name
+			}
+			clusters[l] = name
+			stats[l].sample(root, doc.Name)
+		}
+		id := doc.Id
+		if rep, ok := id2exact[id]; ok {
+			id = "=" + id
+			if exactOut != nil {
+				fmt.Fprintln(exactOut, rep, doc.Id)
+			}
+		}
+		fmt.Println(strings.Join(clusters, " "), id, doc.Name)
+	})
+	timer.timePhase("output", start)
+
+	for l := range stats {
+		for i := range stats[l].Largest {
+			c := &stats[l].Largest[i]
+			c.Id = names[l][c.Id]
+		}
+		stats[l].Phases = timer.Phases
+		// The phases are shared, so they are only printed once, at the
+		// end.
+		s := stats[l]
+		if l < len(stats)-1 {
+			s.Phases = nil
+		}
+		s.Print(os.Stderr)
+		fmt.Fprintln(os.Stderr)
+	}
+	if dd.StatsFile != "" {
+		if err := writeJSON(dd.StatsFile, stats); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnionFind(t *testing.T) {
+	u := make(unionFind)
+	u.union("a", "b")
+	u.union("c", "d")
+	u.union("b", "d")
+	u.union("e", "e")
+	for _, id := range []string{"b", "c", "d"} {
+		if u.find(id) != u.find("a") {
+			t.Errorf("%s is not with a", id)
+		}
+	}
+	if u.find("e") == u.find("a") || u.find("f") != "f" {
+		t.Error("e and f should be on their own")
+	}
+}
+
+func TestDedupeLevels(t *testing.T) {
+	tsv := DelimitedReader{Comma: '\t', IdCol: "0", TextCol: "1"}
+	dir := t.TempDir()
+	// a and b have Jaccard similarity 0.9, b and c 0.67, a and c 0.6, and
+	// d shares nothing, so c only joins a and b at the lower threshold.
+	input := writeTSV(t, dir, "in.tsv",
+		"a\t"+strings.Join(words(0, 100), " "),
+		"b\t"+strings.Join(words(5, 100), " "),
+		"c\t"+strings.Join(words(25, 100), " "),
+		"d\t"+strings.Join(words(1000, 100), " "))
+
+	dd := MakeDeduper(MakeLSH(128, 64), *NewMinhash(128), tsv.Read)
+	dd.Normalizer = Normalizer{}
+	dd.Shingler.Size = 1
+	dd.Thresholds = []float64{0.8, 0.4}
+	out := captureStdout(t, func() { dd.Dedupe(input) })
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "#single-link@0.4 single-link@0.8 docid") {
+		t.Fatalf("Got output %q", out)
+	}
+	want := []string{"a a a", "a a b", "a c c", "d d d"}
+	for i, line := range lines[1:] {
+		if got := strings.Join(strings.Fields(line)[:3], " "); got != want[i] {
+			t.Errorf("Got clusters %q, want %q", got, want[i])
+		}
+	}
+}
@@ -73,6 +73,18 @@ func (h LSH) Query(hashes []uint32) []string {
 	return result
 }
 
+// QueryBands returns the candidates for a signature along with the bands,
+// numbered from zero, in which each one collided with it.
+func (h LSH) QueryBands(hashes []uint32) map[string][]int {
+	candidates := make(map[string][]int)
+	prints := h.bandprints(hashes)
+	for b, p := range prints {
+		for _, c := range h.maps[b][p] {
+			candidates[c] = append(candidates[c], b)
+		}
+	}
+	return candidates
+}
 
 func computeBands(num_hashes int, thresh float64) (num_bands int) {
 	var t, last_t float64
@@ -0,0 +1,179 @@
+package lib
+
+import (
+	"encoding/binary"
+	"log"
+	"math"
+	"sort"
+	"strings"
+)
+
+// An LSHEnsemble answers containment queries: given a query document Q and
+// a threshold t, it finds the documents X with |Q∩X| / |Q| >= t.  Jaccard
+// similarity misses these when X is much longer than Q, as when a wire
+// story is reprinted inside a roundup article.
+//
+// This follows Zhu et al., "LSH Ensemble: Internet-Scale Domain Search"
+// (VLDB 2016).  Documents are partitioned by shingle-set size, and each
+// partition is indexed with bands of up to max_rows hashes kept in sorted
+// order, as in the LSH forest.  At query time the containment threshold is
+// converted to a Jaccard threshold using the partition's largest set size,
+// and the number of bands and rows used is tuned to that threshold.
+type LSHEnsemble struct {
+	partitions []ensemblePartition
+	sigs       map[string][]uint32
+	sizes      map[string]int
+	num_hashes int
+	num_part   int
+	max_rows   int
+	num_bands  int
+	indexed    bool
+}
+
+type ensemblePartition struct {
+	upper int
+	bands [][]forestEntry
+}
+
+func MakeLSHEnsemble(num_hashes, num_part, max_rows int) *LSHEnsemble {
+	if max_rows < 1 || max_rows > num_hashes {
+		log.Panicf("Rows %d must be between 1 and num_hashes %d\n", max_rows, num_hashes)
+	}
+	if num_part < 1 {
+		log.Panicf("Partitions %d must be at least 1\n", num_part)
+	}
+	e := new(LSHEnsemble)
+	e.num_hashes = num_hashes
+	e.num_part = num_part
+	e.max_rows = max_rows
+	e.num_bands = num_hashes / max_rows
+	e.sigs = make(map[string][]uint32)
+	e.sizes = make(map[string]int)
+	log.Printf("LSH ensemble with %d partitions, up to %d bands of %d rows\n",
+		num_part, e.num_bands, max_rows)
+	return e
+}
+
+// Insert adds a document with its minhash signature and the number of
+// shingles it was computed from.
+func (e *LSHEnsemble) Insert(key string, hashes []uint32, size int) {
+	e.sigs[key] = hashes
+	e.sizes[key] = size
+	e.indexed = false
+}
+
+func (e *LSHEnsemble) bandprint(b, rows int, hashes []uint32) string {
+	var s strings.Builder
+	var buf [4]byte
+	for r := 0; r < rows; r++ {
+		binary.BigEndian.PutUint32(buf[:], hashes[b*e.max_rows+r])
+		s.Write(buf[:])
+	}
+	return s.String()
+}
+
+// index splits the documents into equal-sized partitions by set size, and
+// builds the sorted bands for each partition.
+func (e *LSHEnsemble) index() {
+	keys := make([]string, 0, len(e.sizes))
+	for k := range e.sizes {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return e.sizes[keys[i]] < e.sizes[keys[j]]
+	})
+
+	e.partitions = nil
+	per_part := (len(keys) + e.num_part - 1) / e.num_part
+	for start := 0; start < len(keys); {
+		end := start + per_part
+		if end > len(keys) {
+			end = len(keys)
+		}
+		// Don't split documents of the same size across partitions.
+		for end < len(keys) && e.sizes[keys[end]] == e.sizes[keys[end-1]] {
+			end++
+		}
+		var p ensemblePartition
+		p.upper = e.sizes[keys[end-1]]
+		p.bands = make([][]forestEntry, e.num_bands)
+		for b := range p.bands {
+			p.bands[b] = make([]forestEntry, 0, end-start)
+			for _, k := range keys[start:end] {
+				p.bands[b] = append(p.bands[b], forestEntry{e.bandprint(b, e.max_rows, e.sigs[k]), k})
+			}
+			band := p.bands[b]
+			sort.Slice(band, func(i, j int) bool {
+				return band[i].prefix < band[j].prefix
+			})
+		}
+		e.partitions = append(e.partitions, p)
+		start = end
+	}
+	e.indexed = true
+}
+
+// params picks the number of bands and rows whose LSH threshold is closest
+// to, without exceeding, the Jaccard threshold.
+func (e *LSHEnsemble) params(jaccard float64) (bands, rows int) {
+	bands, rows = e.num_bands, 1
+	best := -1.0
+	for r := 1; r <= e.max_rows; r++ {
+		for b := 1; b <= e.num_bands; b++ {
+			t := math.Pow(1.0/float64(b), 1.0/float64(r))
+			if t <= jaccard && t > best {
+				best = t
+				bands, rows = b, r
+			}
+		}
+	}
+	return bands, rows
+}
+
+// Query returns the documents estimated to contain at least the threshold
+// fraction of a query document with the given signature and set size.
+func (e *LSHEnsemble) Query(hashes []uint32, size int, threshold float64) []string {
+	if !e.indexed {
+		e.index()
+	}
+	var result []string
+	for _, p := range e.partitions {
+		if float64(p.upper) < threshold*float64(size) {
+			continue
+		}
+		// The smallest Jaccard a document in this partition could have
+		// and still reach the containment threshold.
+		q := float64(size)
+		jaccard := threshold * q / (q + float64(p.upper) - threshold*q)
+		bands, rows := e.params(jaccard)
+
+		candidates := make(map[string]bool)
+		for b := 0; b < bands; b++ {
+			prefix := e.bandprint(b, rows, hashes)
+			band := p.bands[b]
+			i := sort.Search(len(band), func(i int) bool {
+				return band[i].prefix >= prefix
+			})
+			for ; i < len(band) && strings.HasPrefix(band[i].prefix, prefix); i++ {
+				candidates[band[i].key] = true
+			}
+		}
+		for c := range candidates {
+			if EstimateContainment(hashes, e.sigs[c], size, e.sizes[c]) >= threshold {
+				result = append(result, c)
+			}
+		}
+	}
+	return result
+}
+
+// EstimateContainment estimates |A∩B| / |A| from the minhash signatures
+// and set sizes of A and B.
+func EstimateContainment(a, b []uint32, size_a, size_b int) float64 {
+	if size_a == 0 {
+		return 0.0
+	}
+	j := EstimateJaccard(a, b)
+	c := j * float64(size_a+size_b) / ((1.0 + j) * float64(size_a))
+	return math.Min(c, 1.0)
+}
@@ -0,0 +1,38 @@
+package lib
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestEnsembleContainment(t *testing.T) {
+	// The container is found with high probability, not always, so the
+	// minhash functions come from a fixed source to keep the test steady.
+	mh := newMinhash(256, rand.New(rand.NewSource(1)).Uint32)
+	e := MakeLSHEnsemble(256, 4, 8)
+
+	short := make([]uint32, 50)
+	for i := range short {
+		short[i] = uint32(i)
+	}
+	// The first document contains the short one inside a much longer
+	// text, so its Jaccard similarity is only 0.1.
+	long := make([]uint32, 500)
+	for i := range long {
+		long[i] = uint32(i)
+	}
+	e.Insert("container", mh.Hash(long), len(long))
+	for d := 0; d < 20; d++ {
+		other := make([]uint32, 100+20*d)
+		for i := range other {
+			other[i] = uint32(1000000*(d+1) + i)
+		}
+		e.Insert(fmt.Sprintf("other%d", d), mh.Hash(other), len(other))
+	}
+
+	result := e.Query(mh.Hash(short), len(short), 0.8)
+	if len(result) != 1 || result[0] != "container" {
+		t.Errorf("Expected only the container, got %v", result)
+	}
+}
@@ -0,0 +1,118 @@
+package lib
+
+import (
+	"encoding/binary"
+	"log"
+	"sort"
+	"strings"
+)
+
+// An LSHForest answers top-k nearest neighbor queries over minhash
+// signatures, following Bawa et al., "LSH Forest: Self-Tuning Indexes for
+// Similarity Search" (WWW 2005).  The signature is split into one slice of
+// hashes per tree, and each tree keeps its slices sorted so that a query can
+// match on progressively shorter prefixes until it has enough candidates.
+// Candidates are ranked by the Jaccard similarity estimated from their
+// signatures.
+type LSHForest struct {
+	trees     [][]forestEntry
+	sigs      map[string][]uint32
+	depth     int
+	num_trees int
+	sorted    bool
+}
+
+type forestEntry struct {
+	prefix string
+	key    string
+}
+
+// A Match is a document returned from a nearest neighbor query, along with
+// its estimated Jaccard similarity to the query.
+type Match struct {
+	Id      string
+	Jaccard float64
+}
+
+func MakeLSHForest(num_hashes, num_trees int) *LSHForest {
+	if num_trees < 1 || num_trees > num_hashes {
+		log.Panicf("Trees %d must be between 1 and num_hashes %d\n", num_trees, num_hashes)
+	}
+	f := new(LSHForest)
+	f.num_trees = num_trees
+	f.depth = num_hashes / num_trees
+	f.trees = make([][]forestEntry, num_trees)
+	f.sigs = make(map[string][]uint32)
+	log.Printf("LSH forest with %d trees of depth %d\n", f.num_trees, f.depth)
+	return f
+}
+
+func (f *LSHForest) treeprint(t int, hashes []uint32) string {
+	var s strings.Builder
+	var buf [4]byte
+	for r := 0; r < f.depth; r++ {
+		// Big-endian so that byte prefixes line up with hash prefixes.
+		binary.BigEndian.PutUint32(buf[:], hashes[t*f.depth+r])
+		s.Write(buf[:])
+	}
+	return s.String()
+}
+
+func (f *LSHForest) Insert(key string, hashes []uint32) {
+	for t := range f.trees {
+		f.trees[t] = append(f.trees[t], forestEntry{f.treeprint(t, hashes), key})
+	}
+	f.sigs[key] = hashes
+	f.sorted = false
+}
+
+func (f *LSHForest) sort() {
+	for _, tree := range f.trees {
+		sort.Slice(tree, func(i, j int) bool {
+			return tree[i].prefix < tree[j].prefix
+		})
+	}
+	f.sorted = true
+}
+
+// Query returns up to k documents most similar to the signature, best first.
+func (f *LSHForest) Query(hashes []uint32, k int) []Match {
+	if k <= 0 {
+		return nil
+	}
+	if !f.sorted {
+		f.sort()
+	}
+	prints := make([]string, f.num_trees)
+	for t := range f.trees {
+		prints[t] = f.treeprint(t, hashes)
+	}
+
+	candidates := make(map[string]bool)
+	for r := f.depth; r > 0 && len(candidates) < k; r-- {
+		for t, tree := range f.trees {
+			prefix := prints[t][:r*4]
+			i := sort.Search(len(tree), func(i int) bool {
+				return tree[i].prefix >= prefix
+			})
+			for ; i < len(tree) && strings.HasPrefix(tree[i].prefix, prefix); i++ {
+				candidates[tree[i].key] = true
+			}
+		}
+	}
+
+	result := make([]Match, 0, len(candidates))
+	for c := range candidates {
+		result = append(result, Match{c, EstimateJaccard(hashes, f.sigs[c])})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Jaccard != result[j].Jaccard {
+			return result[i].Jaccard > result[j].Jaccard
+		}
+		return result[i].Id < result[j].Id
+	})
+	if len(result) > k {
+		result = result[:k]
+	}
+	return result
+}
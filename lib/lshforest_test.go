@@ -0,0 +1,39 @@
+package lib
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestForestRanking(t *testing.T) {
+	mh := NewMinhash(256)
+	f := MakeLSHForest(256, 8)
+
+	base := make([]uint32, 200)
+	for i := range base {
+		base[i] = uint32(i)
+	}
+	// Document i shares 200-20*i shingles with base.
+	for i := 0; i < 6; i++ {
+		doc := make([]uint32, 200)
+		copy(doc, base)
+		for j := 0; j < 20*i; j++ {
+			doc[j] = uint32(100000*(i+1) + j)
+		}
+		f.Insert(fmt.Sprintf("doc%d", i), mh.Hash(doc))
+	}
+
+	result := f.Query(mh.Hash(base), 3)
+	if len(result) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(result))
+	}
+	if result[0].Id != "doc0" || result[0].Jaccard != 1.0 {
+		t.Errorf("Expected doc0 with similarity 1.0 first, got %s %.3f",
+			result[0].Id, result[0].Jaccard)
+	}
+	for i := 1; i < len(result); i++ {
+		if result[i].Jaccard > result[i-1].Jaccard {
+			t.Errorf("Results out of order: %v", result)
+		}
+	}
+}
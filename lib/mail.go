@@ -0,0 +1,215 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// A MailReader reads email messages from an mbox file, or a single message
+// from a file such as one in a maildir.  The document id is the message's
+// Message-ID, the name is its subject, the date is its Date header, and the
+// text is its body.  MIME messages are taken apart and quoted-printable and
+// base64 parts decoded; the plain text parts are used, or the HTML parts if
+// there are none, and attachments are skipped.  With StripQuotes, text
+// quoted from earlier messages in a reply is removed.
+//
+// The raw record of a message read from an mbox file is as it was in the
+// input.  A message from a file of its own is made into an mbox entry,
+// with a From_ line and mboxrd quoting, so that the raw records of
+// messages can be written one after another as an mbox file.
+type MailReader struct {
+	StripQuotes bool
+}
+
+// Read sends each message in the stream to the channel, and closes the
+// channel at the end.
+func (m MailReader) Read(reader *bufio.Reader, c chan Document) {
+	defer close(c)
+	// buf holds the message, and raw holds it as it was in the input.
+	var buf bytes.Buffer
+	var raw strings.Builder
+	is_mbox := false
+	first := true
+	send := func() {
+		doc := m.parse(raw.String(), buf.Bytes())
+		if !is_mbox {
+			doc.Raw = mboxEntry(doc.Raw, doc.Date)
+		}
+		c <- doc
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if first && strings.TrimSpace(line) != "" {
+			is_mbox = strings.HasPrefix(line, "From ")
+			first = false
+		}
+		if is_mbox && strings.HasPrefix(line, "From ") {
+			if buf.Len() > 0 {
+				send()
+				buf.Reset()
+				raw.Reset()
+			}
+			raw.WriteString(line)
+		} else {
+			raw.WriteString(line)
+			if is_mbox && strings.HasPrefix(strings.TrimLeft(line, ">"), "From ") {
+				// Undo mboxrd quoting of lines that look like separators.
+				line = line[1:]
+			}
+			buf.WriteString(line)
+		}
+		if err != nil {
+			break
+		}
+	}
+	if strings.TrimSpace(buf.String()) != "" {
+		send()
+	}
+}
+
+var mbox_from_re = regexp.MustCompile(`(?m)^(>*From )`)
+
+// mboxEntry makes a message into an mbox entry, with a From_ line dated
+// by the message, lines that look like separators quoted as in mboxrd,
+// and a blank line after it.
+func mboxEntry(message string, date time.Time) string {
+	if date.IsZero() {
+		date = time.Unix(0, 0)
+	}
+	message = mbox_from_re.ReplaceAllString(message, ">$1")
+	if !strings.HasSuffix(message, "\n") {
+		message += "\n"
+	}
+	return "From MAILER-DAEMON " + date.UTC().Format(time.ANSIC) + "\n" + message + "\n"
+}
+
+// parse makes a document from a message.  The record is the message as it
+// was in the input, including any mbox From_ line.
+func (m MailReader) parse(record string, raw []byte) Document {
+	var doc Document
+	doc.Raw = record
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		doc.Id = rawMessageId(raw)
+		doc.Text = string(raw)
+		doc.Name = documentName("", doc.Text)
+		return doc
+	}
+
+	doc.Id = strings.Trim(strings.TrimSpace(msg.Header.Get("Message-Id")), "<>")
+	if doc.Id == "" {
+		doc.Id = rawMessageId(raw)
+	}
+	dec := &mime.WordDecoder{CharsetReader: charsetReader}
+	subject, err := dec.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+
+	plain, html := mailText(msg.Header.Get("Content-Type"),
+		msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	doc.Text = plain
+	if strings.TrimSpace(plain) == "" {
+		doc.Text = ExtractText(html)
+	}
+	if m.StripQuotes {
+		doc.Text = StripQuotedReply(doc.Text)
+	}
+	doc.Name = documentName(subject, doc.Text)
+	if date, err := msg.Header.Date(); err == nil {
+		doc.Date = date
+	}
+	return doc
+}
+
+// charsetReader decodes header words in any charset that a web browser
+// knows, such as ISO-2022-JP or windows-1251, to UTF-8.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, err
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+// rawMessageId makes an id for a message without a Message-ID.
+func rawMessageId(raw []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(raw))
+}
+
+// mailText returns the decoded plain text and HTML in a message body or
+// MIME part, descending into multipart parts.
+func mailText(ctype, encoding string, body io.Reader) (plain, html string) {
+	mediatype, params, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		mediatype, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediatype, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		var pbuf, hbuf strings.Builder
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			if strings.HasPrefix(part.Header.Get("Content-Disposition"), "attachment") {
+				continue
+			}
+			// multipart.Reader already decodes quoted-printable parts.
+			p, h := mailText(part.Header.Get("Content-Type"),
+				part.Header.Get("Content-Transfer-Encoding"), part)
+			pbuf.WriteString(p)
+			hbuf.WriteString(h)
+		}
+		return pbuf.String(), hbuf.String()
+	}
+	if mediatype != "text/plain" && mediatype != "text/html" {
+		return "", ""
+	}
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	if charset := params["charset"]; charset != "" {
+		if enc, err := htmlindex.Get(charset); err == nil {
+			body = enc.NewDecoder().Reader(body)
+		}
+	}
+	data, _ := ioutil.ReadAll(body)
+	text := string(data) + "\n"
+	if mediatype == "text/html" {
+		return "", text
+	}
+	return text, ""
+}
+
+var reply_header_re = regexp.MustCompile(`(?m)^(On .*wrote:|-+ ?Original Message ?-+|-+ ?Forwarded message ?-+)\s*$`)
+var quoted_line_re = regexp.MustCompile(`(?m)^[ \t]*>.*\n?`)
+
+// StripQuotedReply removes the text a reply quotes from earlier messages:
+// lines starting with ">", and everything after an "On ... wrote:" or
+// "Original Message" line.
+func StripQuotedReply(text string) string {
+	if loc := reply_header_re.FindStringIndex(text); loc != nil {
+		text = text[:loc[0]]
+	}
+	return quoted_line_re.ReplaceAllLiteralString(text, "")
+}
@@ -0,0 +1,59 @@
+package lib
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestMailReader(t *testing.T) {
+	mbox := "From alice@example.com Mon Jan  1 00:00:00 2001\n" +
+		"Message-ID: <1@example.com>\n" +
+		"Subject: =?UTF-8?Q?Caf=C3=A9_plans?=\n" +
+		"Content-Type: text/plain; charset=utf-8\n" +
+		"Content-Transfer-Encoding: quoted-printable\n" +
+		"\n" +
+		"Let's meet at the caf=C3=A9 at noon.\n" +
+		">From the office, of course.\n" +
+		"\n" +
+		"From bob@example.com Mon Jan  1 00:00:00 2001\n" +
+		"Message-ID: <2@example.com>\n" +
+		"Subject: Re: =?windows-1251?B?z/Do4uXy?=\n" +
+		"MIME-Version: 1.0\n" +
+		"Content-Type: multipart/mixed; boundary=XX\n" +
+		"\n" +
+		"--XX\n" +
+		"Content-Type: text/plain\n" +
+		"Content-Transfer-Encoding: base64\n" +
+		"\n" +
+		"U291bmRzIGdvb2QuCgpPbiBNb24sIEFsaWNlIHdyb3RlOgo+IExldCdzIG1lZXQu\n" +
+		"--XX\n" +
+		"Content-Type: application/pdf\n" +
+		"Content-Disposition: attachment; filename=menu.pdf\n" +
+		"\n" +
+		"%PDF\n" +
+		"--XX--\n"
+
+	c := make(chan Document)
+	go MailReader{StripQuotes: true}.Read(bufio.NewReader(strings.NewReader(mbox)), c)
+	var docs []Document
+	for doc := range c {
+		docs = append(docs, doc)
+	}
+
+	if len(docs) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(docs))
+	}
+	if docs[0].Id != "1@example.com" || docs[0].Name != "Café plans" {
+		t.Errorf("Bad first message %q %q", docs[0].Id, docs[0].Name)
+	}
+	if strings.TrimSpace(docs[0].Text) != "Let's meet at the café at noon.\nFrom the office, of course." {
+		t.Errorf("Bad first text %q", docs[0].Text)
+	}
+	if docs[1].Id != "2@example.com" || strings.TrimSpace(docs[1].Text) != "Sounds good." {
+		t.Errorf("Bad second message %q %q", docs[1].Id, docs[1].Text)
+	}
+	if docs[1].Name != "Re: Привет" {
+		t.Errorf("Bad windows-1251 subject %q", docs[1].Name)
+	}
+}
@@ -16,10 +16,16 @@ type MinHasher struct {
 }
 
 func NewMinhash(num_hashes int) *MinHasher {
+	return newMinhash(num_hashes, rand.Uint32)
+}
+
+// newMinhash picks the hash coefficients using random, so tests can use a
+// fixed source.
+func newMinhash(num_hashes int, random func() uint32) *MinHasher {
 	mh := new(MinHasher)
 	mh.num_hashes = num_hashes
-	mh.coeffA = pickRandCoeffs(num_hashes)
-	mh.coeffB = pickRandCoeffs(num_hashes)
+	mh.coeffA = pickRandCoeffs(num_hashes, random)
+	mh.coeffB = pickRandCoeffs(num_hashes, random)
 	return mh
 }
 
@@ -42,16 +48,16 @@ func (mh* MinHasher) Hash(doc []uint32) (sigs []uint32) {
 	return sigs
 }
 
-func pickRandCoeffs(k int) (result []uint32) {
+func pickRandCoeffs(k int, random func() uint32) (result []uint32) {
 	result = make([]uint32, k)
 	var seen map[uint32]bool
 
 	seen = make(map[uint32]bool, k)
 	i := 0
 	for k > 0 {
-		randIndex := rand.Uint32() % max_value
+		randIndex := random() % max_value
 		for seen[randIndex] {
-			randIndex = rand.Uint32() % max_value
+			randIndex = random() % max_value
 		}
 		result[i] = randIndex
 		seen[randIndex] = true
@@ -62,4 +68,17 @@ func pickRandCoeffs(k int) (result []uint32) {
 	return result
 }
 
-	
+// EstimateJaccard estimates the Jaccard similarity of two documents as the
+// fraction of minhash positions on which their signatures agree.
+func EstimateJaccard(a, b []uint32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0.0
+	}
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
@@ -0,0 +1,139 @@
+package lib
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// A Normalizer cleans up document text before shingling by running it
+// through a chain of steps.  The zero Normalizer leaves text unchanged.
+type Normalizer struct {
+	steps []func(string) string
+	names []string
+}
+
+var html_re = regexp.MustCompile(`<[^>]+?>`)
+var nonletter_re = regexp.MustCompile(`[^\pL]+`)
+var digit_re = regexp.MustCompile(`\pN+`)
+var punct_re = regexp.MustCompile(`[\pP\pS]+`)
+var nonsentence_re = regexp.MustCompile(`[^\pL\pN\pM\s.!?。！？]+`)
+var space_re = regexp.MustCompile(`\s+`)
+
+// normalizeSteps are the steps a Normalizer can be made from.  Steps
+// with a policy are named "step:policy".
+var normalizeSteps = map[string]func(string) string{
+	// trim removes leading and trailing white space.
+	"trim": strings.TrimSpace,
+	// lower lowercases the text.
+	"lower": strings.ToLower,
+	// casefold applies full Unicode case folding, so that for example
+	// "Straße" and "STRASSE" match.
+	"casefold": func(s string) string {
+		return cases.Fold().String(s)
+	},
+	// nfkc applies Unicode compatibility normalization, folding full-width
+	// forms, ligatures and presentation forms to their plain equivalents.
+	"nfkc": norm.NFKC.String,
+	// diacritics removes nonspacing marks such as accents.  Do not use it
+	// for scripts where vowels are marks, such as Thai or Hindi.
+	"diacritics": func(s string) string {
+		t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+		result, _, err := transform.String(t, s)
+		if err != nil {
+			return s
+		}
+		return result
+	},
+	// entities decodes HTML character entities like &amp; and &#8217;.
+	"entities": html.UnescapeString,
+	// html replaces HTML tags with spaces.
+	"html": func(s string) string {
+		return html_re.ReplaceAllLiteralString(s, " ")
+	},
+	// letters replaces everything but letters with spaces, which also
+	// removes digits.
+	"letters": func(s string) string {
+		return nonletter_re.ReplaceAllLiteralString(s, " ")
+	},
+	// digits:remove replaces numbers with spaces, and digits:zero replaces
+	// every digit with 0 so that numbers match regardless of value.
+	"digits:remove": func(s string) string {
+		return digit_re.ReplaceAllLiteralString(s, " ")
+	},
+	"digits:zero": func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if unicode.IsDigit(r) {
+				return '0'
+			}
+			return r
+		}, s)
+	},
+	// punct:space replaces punctuation and symbols with spaces,
+	// punct:remove deletes them, and punct:sentence keeps only sentence
+	// ending punctuation, for use with sentence shingles.
+	"punct:space": func(s string) string {
+		return punct_re.ReplaceAllLiteralString(s, " ")
+	},
+	"punct:remove": func(s string) string {
+		return punct_re.ReplaceAllLiteralString(s, "")
+	},
+	"punct:sentence": func(s string) string {
+		return nonsentence_re.ReplaceAllLiteralString(s, " ")
+	},
+	// squeeze collapses runs of white space to a single space.
+	"squeeze": func(s string) string {
+		return strings.TrimSpace(space_re.ReplaceAllLiteralString(s, " "))
+	},
+}
+
+// NormalizeProfiles are the built-in normalizer profiles.  "legacy" is what
+// the collection commands have always done.
+var NormalizeProfiles = map[string][]string{
+	"legacy":  {"trim", "lower", "html", "letters"},
+	"unicode": {"html", "entities", "nfkc", "casefold", "punct:space", "squeeze"},
+	"none":    {},
+}
+
+func MakeNormalizer(steps []string) (Normalizer, error) {
+	var n Normalizer
+	for _, name := range steps {
+		step, ok := normalizeSteps[name]
+		if !ok {
+			return Normalizer{}, fmt.Errorf("unknown normalization step %q", name)
+		}
+		n.steps = append(n.steps, step)
+		n.names = append(n.names, name)
+	}
+	return n, nil
+}
+
+func (n Normalizer) Normalize(s string) string {
+	for _, step := range n.steps {
+		s = step(s)
+	}
+	return s
+}
+
+func (n Normalizer) String() string {
+	return strings.Join(n.names, ",")
+}
+
+// KeepsSentences says whether text still has its sentence punctuation
+// after normalizing, which sentence shingles need.
+func (n Normalizer) KeepsSentences() bool {
+	for _, name := range n.names {
+		switch name {
+		case "letters", "punct:space", "punct:remove":
+			return false
+		}
+	}
+	return true
+}
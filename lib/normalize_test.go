@@ -0,0 +1,59 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestNormalizeLegacy(t *testing.T) {
+	n, err := MakeNormalizer(NormalizeProfiles["legacy"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := n.Normalize("  <p>Hello, World 2020!</p> ")
+	if got != " hello world " {
+		t.Errorf("Got %q", got)
+	}
+}
+
+func TestNormalizeSteps(t *testing.T) {
+	cases := []struct {
+		steps []string
+		text  string
+		want  string
+	}{
+		{[]string{"nfkc"}, "ﬁle ＡＢＣ", "file ABC"},
+		{[]string{"casefold"}, "Straße", "strasse"},
+		{[]string{"diacritics"}, "café naïve", "cafe naive"},
+		{[]string{"entities"}, "AT&amp;T&#8217;s", "AT&T’s"},
+		{[]string{"digits:zero"}, "route 66", "route 00"},
+		{[]string{"digits:remove", "squeeze"}, "route 66 west", "route west"},
+		{[]string{"punct:space", "squeeze"}, "a,b; c--d", "a b c d"},
+		{[]string{"punct:sentence", "squeeze"}, "Yes, no. Maybe?", "Yes no. Maybe?"},
+	}
+	for _, c := range cases {
+		n, err := MakeNormalizer(c.steps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := n.Normalize(c.text); got != c.want {
+			t.Errorf("%v: Normalize(%q) = %q, want %q", c.steps, c.text, got, c.want)
+		}
+	}
+
+	if _, err := MakeNormalizer([]string{"stem"}); err == nil {
+		t.Error("Expected an error for an unknown step")
+	}
+}
+
+func TestKeepsSentences(t *testing.T) {
+	for profile, want := range map[string]bool{"legacy": false, "unicode": false, "none": true} {
+		n, _ := MakeNormalizer(NormalizeProfiles[profile])
+		if n.KeepsSentences() != want {
+			t.Errorf("%s: KeepsSentences() = %v, want %v", profile, !want, want)
+		}
+	}
+	n, _ := MakeNormalizer([]string{"lower", "punct:sentence", "squeeze"})
+	if !n.KeepsSentences() {
+		t.Error("punct:sentence should keep sentences")
+	}
+}
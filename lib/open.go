@@ -0,0 +1,50 @@
+package lib
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+// compressedFile closes both the decompressor and the file under it.
+type compressedFile struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (f compressedFile) Close() error {
+	var err error
+	for _, c := range f.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// openFile opens a file for reading.  Gzipped and bzip2ed files are
+// recognized by their magic numbers and decompressed, including the
+// multi-member and multistream files that bundles, web crawls and wiki
+// dumps are often stored as.
+func openFile(filename string) (io.ReadCloser, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	buf := bufio.NewReader(file)
+	magic, _ := buf.Peek(3)
+	if string(magic) == "BZh" {
+		return compressedFile{bzip2.NewReader(buf), []io.Closer{file}}, nil
+	}
+	if len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buf)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return compressedFile{gz, []io.Closer{gz, file}}, nil
+	}
+	return compressedFile{buf, []io.Closer{file}}, nil
+}
@@ -0,0 +1,103 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// A Pair is an edge in a cluster: a document and a candidate that LSH
+// found for it and that was put in its cluster.
+type Pair struct {
+	A string `json:"a"`
+	B string `json:"b"`
+	// Estimate is the Jaccard similarity estimated from the signatures.
+	Estimate float64 `json:"jaccard_est"`
+	// Exact is the Jaccard similarity of the shingle sets, if it was
+	// computed.
+	Exact *float64 `json:"jaccard,omitempty"`
+	// Bands are the LSH bands the two signatures collided in.
+	NumBands int   `json:"num_bands"`
+	Bands    []int `json:"bands"`
+}
+
+// A pairWriter writes pairs to a file as JSON lines or tab-separated
+// values.
+type pairWriter struct {
+	file *os.File
+	out  *bufio.Writer
+	tsv  bool
+}
+
+// openPairs opens PairsFile for writing, or returns nil if it isn't set.
+func (dd Deduper) openPairs() *pairWriter {
+	if dd.PairsFile == "" {
+		return nil
+	}
+	var pw pairWriter
+	switch dd.PairsFormat {
+	case "", "jsonl":
+	case "tsv":
+		pw.tsv = true
+	default:
+		log.Fatalf("Unknown pair format %s", dd.PairsFormat)
+	}
+	file, err := os.Create(dd.PairsFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pw.file = file
+	pw.out = bufio.NewWriter(file)
+	if pw.tsv {
+		fmt.Fprintln(pw.out, "a\tb\tjaccard_est\tjaccard\tnum_bands\tbands")
+	}
+	return &pw
+}
+
+// write writes one pair.  In TSV the exact Jaccard is left empty if it
+// wasn't computed, and the bands are separated by commas.
+func (pw *pairWriter) write(p Pair) {
+	if !pw.tsv {
+		line, _ := json.Marshal(p)
+		pw.out.Write(line)
+		pw.out.WriteByte('\n')
+		return
+	}
+	exact := ""
+	if p.Exact != nil {
+		exact = fmt.Sprintf("%.4f", *p.Exact)
+	}
+	bands := make([]string, len(p.Bands))
+	for i, b := range p.Bands {
+		bands[i] = fmt.Sprint(b)
+	}
+	fmt.Fprintf(pw.out, "%s\t%s\t%.4f\t%s\t%d\t%s\n", p.A, p.B, p.Estimate, exact,
+		p.NumBands, strings.Join(bands, ","))
+}
+
+func (pw *pairWriter) close() {
+	pw.out.Flush()
+	pw.file.Close()
+}
+
+// ExactJaccard returns the Jaccard similarity of two sets of distinct
+// shingle fingerprints.
+func ExactJaccard(a, b []uint32) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0.0
+	}
+	set := make(map[uint32]bool, len(a))
+	for _, x := range a {
+		set[x] = true
+	}
+	common := 0
+	for _, x := range b {
+		if set[x] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
@@ -0,0 +1,42 @@
+package lib
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestExactJaccard(t *testing.T) {
+	if j := ExactJaccard([]uint32{1, 2, 3}, []uint32{2, 3, 4}); j != 0.5 {
+		t.Errorf("ExactJaccard = %f, want 0.5", j)
+	}
+	if j := ExactJaccard(nil, nil); j != 0.0 {
+		t.Errorf("ExactJaccard of empty sets = %f, want 0", j)
+	}
+}
+
+func TestQueryBands(t *testing.T) {
+	h := MakeLSH(8, 4)
+	h.Insert("a", []uint32{1, 2, 3, 4, 5, 6, 7, 8})
+	h.Insert("b", []uint32{1, 2, 0, 0, 5, 6, 0, 0})
+	got := h.QueryBands([]uint32{1, 2, 3, 4, 0, 0, 7, 8})
+	if len(got) != 2 {
+		t.Fatalf("Expected two candidates, got %v", got)
+	}
+	if a := got["a"]; len(a) != 3 || a[0] != 0 || a[1] != 1 || a[2] != 3 {
+		t.Errorf("a collided in bands %v, want [0 1 3]", a)
+	}
+	if b := got["b"]; len(b) != 1 || b[0] != 0 {
+		t.Errorf("b collided in bands %v, want [0]", b)
+	}
+}
+
+func TestPairWriterTSV(t *testing.T) {
+	var buf strings.Builder
+	pw := &pairWriter{out: bufio.NewWriter(&buf), tsv: true}
+	pw.write(Pair{A: "x", B: "y", Estimate: 0.75, NumBands: 2, Bands: []int{3, 9}})
+	pw.out.Flush()
+	if want := "x\ty\t0.7500\t\t2\t3,9\n"; buf.String() != want {
+		t.Errorf("Wrote %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,151 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// An AlignedSpan is a passage of one document and the near-duplicate
+// passage of another, with their estimated Jaccard similarity.  Runs of
+// overlapping matched windows are merged into one span, and the
+// similarity is the lowest among them.
+type AlignedSpan struct {
+	A       Span    `json:"a"`
+	B       Span    `json:"b"`
+	Jaccard float64 `json:"jaccard"`
+}
+
+// A PassageMatch is a pair of documents that share near-duplicate
+// passages.  A comes before B in the input.
+type PassageMatch struct {
+	A     string        `json:"a"`
+	B     string        `json:"b"`
+	Spans []AlignedSpan `json:"spans"`
+}
+
+// passage is what is kept of an indexed passage: its signature and where
+// it ends.
+type passage struct {
+	sigs []uint32
+	end  int
+}
+
+// passageKey makes the LSH key "docid#offset" for a passage.
+func passageKey(id string, start int) string {
+	return fmt.Sprintf("%s#%d", id, start)
+}
+
+// splitPassageKey returns the docid and offset in a passage key.
+func splitPassageKey(key string) (string, int) {
+	i := strings.LastIndexByte(key, '#')
+	var start int
+	fmt.Sscan(key[i+1:], &start)
+	return key[:i], start
+}
+
+// alignSpans sorts matched passages and merges those that overlap in both
+// documents into aligned spans.
+func alignSpans(matches []AlignedSpan) []AlignedSpan {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].B.Start != matches[j].B.Start {
+			return matches[i].B.Start < matches[j].B.Start
+		}
+		return matches[i].A.Start < matches[j].A.Start
+	})
+	var spans []AlignedSpan
+	for _, m := range matches {
+		if n := len(spans); n > 0 {
+			last := &spans[n-1]
+			if m.B.Start <= last.B.End && m.A.Start <= last.A.End && m.A.Start >= last.A.Start {
+				if m.A.End > last.A.End {
+					last.A.End = m.A.End
+				}
+				if m.B.End > last.B.End {
+					last.B.End = m.B.End
+				}
+				if m.Jaccard < last.Jaccard {
+					last.Jaccard = m.Jaccard
+				}
+				continue
+			}
+		}
+		spans = append(spans, m)
+	}
+	return spans
+}
+
+// Passages finds pairs of documents that share near-duplicate passages,
+// even when the documents as a whole are not similar.  Each document is
+// split into passages by the segmenter, and each passage is normalized,
+// minhashed and indexed in LSH keyed by "docid#offset".  Before a
+// document's passages are indexed they are looked up among those of the
+// documents before it, so each pair of documents is found once.  Matches
+// are kept if their estimated Jaccard similarity is at least the
+// threshold.  Passages with nothing left to shingle after normalizing,
+// such as lines of numbers under the legacy profile, are skipped, since
+// they would all have the same empty signature.
+//
+// Each pair of documents is written to standard output as a line of JSON
+// with the aligned character offsets of their shared passages, in the
+// text the reader produced.  The signature of every passage is kept in
+// memory.
+func (dd Deduper) Passages(filenames []string, seg Segmenter, threshold float64) {
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	enc := json.NewEncoder(out)
+
+	log.Println("--- Indexing and matching passages by", seg.Unit)
+
+	passages := make(map[string]passage)
+	count, pairs := 0, 0
+	dd.read(filenames, func(doc Document) {
+		spans := seg.Segments(doc.Text)
+		chars := charSpans(doc.Text, spans)
+		sigs := make([][]uint32, len(spans))
+		matches := make(map[string][]AlignedSpan)
+		var order []string
+		for i, sp := range spans {
+			shingles := dd.Shingler.Shingle(dd.Normalizer.Normalize(doc.Text[sp.Start:sp.End]))
+			if len(shingles) == 0 {
+				continue
+			}
+			sigs[i] = dd.Fingerprint(shingles)
+			for _, key := range dd.Query(sigs[i]) {
+				p := passages[key]
+				sim := EstimateJaccard(sigs[i], p.sigs)
+				if sim < threshold {
+					continue
+				}
+				id, start := splitPassageKey(key)
+				if _, ok := matches[id]; !ok {
+					order = append(order, id)
+				}
+				matches[id] = append(matches[id], AlignedSpan{Span{start, p.end}, chars[i], sim})
+			}
+		}
+		for i, sp := range chars {
+			if sigs[i] == nil {
+				continue
+			}
+			key := passageKey(doc.Id, sp.Start)
+			dd.Index(key, sigs[i])
+			passages[key] = passage{sigs[i], sp.End}
+			count++
+		}
+
+		for _, id := range order {
+			if id == doc.Id {
+				continue
+			}
+			enc.Encode(PassageMatch{id, doc.Id, alignSpans(matches[id])})
+			pairs++
+		}
+	})
+	log.Println(count, "passages indexed")
+	log.Println(pairs, "document pairs share passages")
+}
@@ -0,0 +1,65 @@
+package lib
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPassageKey(t *testing.T) {
+	id, start := splitPassageKey(passageKey("doc#7", 120))
+	if id != "doc#7" || start != 120 {
+		t.Errorf("splitPassageKey gave %q, %d", id, start)
+	}
+}
+
+func TestAlignSpans(t *testing.T) {
+	matches := []AlignedSpan{
+		{Span{150, 250}, Span{50, 150}, 0.9},
+		{Span{100, 200}, Span{0, 100}, 1.0},
+		{Span{900, 1000}, Span{500, 600}, 0.8},
+	}
+	got := alignSpans(matches)
+	want := []AlignedSpan{
+		{Span{100, 250}, Span{0, 150}, 0.9},
+		{Span{900, 1000}, Span{500, 600}, 0.8},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Span %d is %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPassagesEmpty(t *testing.T) {
+	csv := DelimitedReader{Comma: ',', Quotes: true, IdCol: "0", TextCol: "1"}
+	dir := t.TempDir()
+	// The legacy profile leaves nothing of the lines of numbers, which
+	// are all that a and b share.
+	input := writeFile(t, dir, "in.csv",
+		"a,\"12345\n67890\nthe weather was fine today in the park\"\n"+
+			"b,\"12345\n67890\nan unrelated story about trains and stations\"\n"+
+			"c,\"the weather was fine today in the park\"\n")
+
+	dd := MakeDeduper(MakeLSH(128, 32), *NewMinhash(128), csv.Read)
+	dd.Shingler.Size = 3
+	out := captureStdout(t, func() {
+		dd.Passages([]string{input}, Segmenter{Unit: SEGMENT_PARAGRAPH}, 0.8)
+	})
+
+	var got []string
+	dec := json.NewDecoder(strings.NewReader(out))
+	for {
+		var m PassageMatch
+		if err := dec.Decode(&m); err != nil {
+			break
+		}
+		got = append(got, m.A+" "+m.B)
+	}
+	if strings.Join(got, ",") != "a c" {
+		t.Errorf("Got matches %v, want only a c", got)
+	}
+}
@@ -0,0 +1,112 @@
+package lib
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// A RepeatSpan is a segment of a document that repeats, exactly or nearly,
+// an earlier segment of the same document.
+type RepeatSpan struct {
+	Span
+	Of      Span    `json:"of"`
+	Jaccard float64 `json:"jaccard"`
+}
+
+// A Repetition reports the repeated segments in a document.  Ratio is the
+// fraction of the characters of the document's segments that repeat
+// earlier segments.
+type Repetition struct {
+	Id    string       `json:"id"`
+	Name  string       `json:"name"`
+	Ratio float64      `json:"ratio"`
+	Spans []RepeatSpan `json:"spans"`
+}
+
+// FindRepeats segments the text and finds the segments whose estimated
+// Jaccard similarity to an earlier segment is at least the threshold.
+// Each segment is normalized and shingled on its own.  A repeat is paired
+// with the most similar earlier segment that is not itself a repeat.
+// Segments with nothing left to shingle after normalizing, such as lines of
+// numbers under the legacy profile, are never repeats, since they would
+// all have the same empty signature.
+func (dd Deduper) FindRepeats(text string, seg Segmenter, threshold float64) (float64, []RepeatSpan) {
+	spans := seg.Segments(text)
+	norms := make([]string, len(spans))
+	sigs := make([][]uint32, len(spans))
+	total := 0
+	for i, sp := range spans {
+		norms[i] = dd.Normalizer.Normalize(text[sp.Start:sp.End])
+		if shingles := dd.Shingler.Shingle(norms[i]); strings.TrimSpace(norms[i]) != "" && len(shingles) > 0 {
+			sigs[i] = dd.Fingerprint(shingles)
+		}
+		total += utf8.RuneCountInString(text[sp.Start:sp.End])
+	}
+
+	var repeats []RepeatSpan
+	repeated := 0
+	exact := make(map[[sha256.Size]byte]int)
+	var originals []int
+	for j, sp := range spans {
+		if sigs[j] == nil {
+			continue
+		}
+		h := sha256.Sum256([]byte(norms[j]))
+		best, best_sim := -1, 0.0
+		if i, ok := exact[h]; ok {
+			best, best_sim = i, 1.0
+		} else {
+			for _, i := range originals {
+				if sim := EstimateJaccard(sigs[i], sigs[j]); sim >= threshold && sim > best_sim {
+					best, best_sim = i, sim
+				}
+			}
+		}
+		if best < 0 {
+			exact[h] = j
+			originals = append(originals, j)
+			continue
+		}
+		repeats = append(repeats, RepeatSpan{sp, spans[best], best_sim})
+		repeated += utf8.RuneCountInString(text[sp.Start:sp.End])
+	}
+	if total == 0 {
+		return 0.0, nil
+	}
+	return float64(repeated) / float64(total), repeats
+}
+
+// Repeats reports the documents in the files with repeated segments, such
+// as paragraphs duplicated by a broken scraper.  Each document whose
+// repetition ratio is over minRatio is written to standard output as a
+// line of JSON, with the character offsets of its repeated spans in the
+// text the reader produced.
+func (dd Deduper) Repeats(filenames []string, seg Segmenter, threshold, minRatio float64) {
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	enc := json.NewEncoder(out)
+
+	found := 0
+	dd.read(filenames, func(doc Document) {
+		ratio, spans := dd.FindRepeats(doc.Text, seg, threshold)
+		if len(spans) == 0 || ratio <= minRatio {
+			return
+		}
+		offsets := make([]Span, 0, 2*len(spans))
+		for _, r := range spans {
+			offsets = append(offsets, r.Span, r.Of)
+		}
+		offsets = charSpans(doc.Text, offsets)
+		for i := range spans {
+			spans[i].Span, spans[i].Of = offsets[2*i], offsets[2*i+1]
+		}
+		enc.Encode(Repetition{doc.Id, doc.Name, ratio, spans})
+		found++
+	})
+	log.Println(found, "documents with repetition")
+}
@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestFindRepeats(t *testing.T) {
+	dd := MakeDeduper(MakeLSH(128, 32), *NewMinhash(128), nil)
+	dd.Normalizer = Normalizer{}
+	dd.Shingler.Size = 3
+	para := "the committee met on tuesday to discuss the budget for next year"
+	text := para + "\n" +
+		"an entirely different paragraph about the weather and the harvest season\n" +
+		para + "\n" +
+		"The committee met on Tuesday to discuss the budget for next year!\n"
+	ratio, spans := dd.FindRepeats(text, Segmenter{Unit: SEGMENT_PARAGRAPH}, 0.8)
+	if len(spans) != 1 {
+		t.Fatalf("Found %d repeats, want 1: %v", len(spans), spans)
+	}
+	if spans[0].Of.Start != 0 || text[spans[0].Start:spans[0].End] != para || spans[0].Jaccard != 1.0 {
+		t.Errorf("Wrong repeat %+v", spans[0])
+	}
+	if ratio <= 0.2 || ratio >= 0.3 {
+		t.Errorf("Repetition ratio %f, want about a quarter", ratio)
+	}
+
+	dd.Normalizer, _ = MakeNormalizer(NormalizeProfiles["unicode"])
+	_, spans = dd.FindRepeats(text, Segmenter{Unit: SEGMENT_PARAGRAPH}, 0.8)
+	if len(spans) != 2 {
+		t.Errorf("Found %d repeats after normalizing, want 2", len(spans))
+	}
+}
+
+func TestFindRepeatsEmpty(t *testing.T) {
+	dd := MakeDeduper(MakeLSH(128, 32), *NewMinhash(128), nil)
+	// The legacy profile leaves nothing of lines of numbers.
+	text := "1,234\n--\n5,678\nthe committee met on tuesday\n"
+	ratio, spans := dd.FindRepeats(text, Segmenter{Unit: SEGMENT_PARAGRAPH}, 0.8)
+	if len(spans) != 0 || ratio != 0 {
+		t.Errorf("Found repeats %v with ratio %f in lines with no words", spans, ratio)
+	}
+
+	// The ratio is in characters, like the spans.
+	dd.Normalizer = Normalizer{}
+	dd.Shingler.Size = 1
+	text = "café crème\nthe weather today\ncafé crème\n"
+	ratio, _ = dd.FindRepeats(text, Segmenter{Unit: SEGMENT_PARAGRAPH}, 0.8)
+	if want := 10.0 / 37.0; ratio < want-1e-9 || ratio > want+1e-9 {
+		t.Errorf("Repetition ratio %f, want %f", ratio, want)
+	}
+}
@@ -0,0 +1,182 @@
+package lib
+
+import (
+	"fmt"
+	"log"
+	"sort"
+)
+
+// A RepPolicy says which document in a cluster is chosen to represent it.
+// Ties are broken in favor of the document that comes first in the input.
+type RepPolicy int
+
+const (
+	// FIRST picks the document that comes first in the input.
+	FIRST RepPolicy = iota
+	// LONGEST picks the document with the most text.
+	LONGEST
+	// EARLIEST and LATEST pick by document date.  Documents without a
+	// date are only picked if none in the cluster have one.
+	EARLIEST
+	LATEST
+	// SMALLEST_ID picks the lexicographically smallest document id.
+	SMALLEST_ID
+	// CENTROID picks the document most similar to the rest of the
+	// cluster, by estimated Jaccard similarity.
+	CENTROID
+	// SOURCE picks the document whose source comes first in a priority
+	// list.
+	SOURCE
+)
+
+var policyNames = map[string]RepPolicy{
+	"first":       FIRST,
+	"longest":     LONGEST,
+	"earliest":    EARLIEST,
+	"latest":      LATEST,
+	"smallest-id": SMALLEST_ID,
+	"centroid":    CENTROID,
+	"source":      SOURCE,
+}
+
+func (p RepPolicy) String() string {
+	for name, policy := range policyNames {
+		if policy == p {
+			return name
+		}
+	}
+	return fmt.Sprintf("RepPolicy(%d)", int(p))
+}
+
+func MakeRepPolicy(name string) (RepPolicy, error) {
+	p, ok := policyNames[name]
+	if !ok {
+		return FIRST, fmt.Errorf("unknown representative policy %q", name)
+	}
+	return p, nil
+}
+
+// CENTROID_SAMPLE is the most cluster members each candidate is compared
+// with when looking for the centroid, so that huge clusters stay cheap.
+const CENTROID_SAMPLE = 1000
+
+// docInfo is what the representative policies need to know about a
+// document.
+type docInfo struct {
+	order  int
+	length int
+	date   int64
+	dated  bool
+	source string
+	sigs   []uint32
+}
+
+// sourceRank is the position of a source in the priority list, or the
+// length of the list if it isn't there.
+func (dd Deduper) sourceRank(source string) int {
+	for i, s := range dd.Sources {
+		if s == source {
+			return i
+		}
+	}
+	return len(dd.Sources)
+}
+
+// prefer says whether document a should represent a cluster rather than
+// document b.
+func (dd Deduper) prefer(a, b string, ia, ib docInfo) bool {
+	switch dd.Representative {
+	case LONGEST:
+		if ia.length != ib.length {
+			return ia.length > ib.length
+		}
+	case EARLIEST, LATEST:
+		if ia.dated != ib.dated {
+			return ia.dated
+		}
+		if ia.date != ib.date {
+			return (ia.date < ib.date) == (dd.Representative == EARLIEST)
+		}
+	case SMALLEST_ID:
+		return a < b
+	case SOURCE:
+		ra, rb := dd.sourceRank(ia.source), dd.sourceRank(ib.source)
+		if ra != rb {
+			return ra < rb
+		}
+	}
+	return ia.order < ib.order
+}
+
+// centroid returns the member of the cluster with the highest total
+// estimated Jaccard similarity to the other members.
+func centroid(members []string, info map[string]docInfo) string {
+	sample := members
+	if len(sample) > CENTROID_SAMPLE {
+		sample = sample[:CENTROID_SAMPLE]
+	}
+	best, best_score := members[0], -1.0
+	for _, m := range members {
+		score := 0.0
+		for _, o := range sample {
+			if o != m {
+				score += EstimateJaccard(info[m].sigs, info[o].sigs)
+			}
+		}
+		if score > best_score {
+			best, best_score = m, score
+		}
+	}
+	return best
+}
+
+// chooseReps picks the representative of each cluster with more than one
+// document, and returns a map from cluster ids to representatives.
+func (dd Deduper) chooseReps(id2cluster map[string]string, info map[string]docInfo) map[string]string {
+	clusters := make(map[string][]string)
+	for id, cluster := range id2cluster {
+		clusters[cluster] = append(clusters[cluster], id)
+	}
+
+	if dd.Representative == EARLIEST || dd.Representative == LATEST {
+		dated := false
+		for _, i := range info {
+			if i.dated {
+				dated = true
+				break
+			}
+		}
+		if !dated {
+			log.Println("No documents have dates, so the first in each cluster represents it")
+		}
+	}
+
+	reps := make(map[string]string)
+	changed := 0
+	for cluster, members := range clusters {
+		if len(members) < 2 {
+			continue
+		}
+		// Put members in input order, so ties and samples don't depend
+		// on map order.
+		sort.Slice(members, func(i, j int) bool {
+			return info[members[i]].order < info[members[j]].order
+		})
+		rep := members[0]
+		if dd.Representative == CENTROID {
+			rep = centroid(members, info)
+		} else {
+			for _, m := range members[1:] {
+				if dd.prefer(m, rep, info[m], info[rep]) {
+					rep = m
+				}
+			}
+		}
+		reps[cluster] = rep
+		if rep != cluster {
+			changed++
+		}
+	}
+	log.Println(changed, "clusters have a new representative by", dd.Representative)
+	return reps
+}
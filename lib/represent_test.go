@@ -0,0 +1,57 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestChooseReps(t *testing.T) {
+	id2cluster := map[string]string{"b": "b", "a": "b", "c": "b", "z": "z"}
+	info := map[string]docInfo{
+		"b": {order: 0, length: 10, source: "wire"},
+		"a": {order: 1, length: 30, date: 200, dated: true, source: "blog"},
+		// Dated 1970-01-01, which must not count as undated.
+		"c": {order: 2, length: 20, date: 0, dated: true, source: "paper"},
+		"z": {order: 3, length: 5},
+	}
+	cases := []struct {
+		policy RepPolicy
+		want   string
+	}{
+		{FIRST, "b"},
+		{LONGEST, "a"},
+		{EARLIEST, "c"},
+		{LATEST, "a"},
+		{SMALLEST_ID, "a"},
+		{SOURCE, "c"},
+	}
+	for _, c := range cases {
+		dd := Deduper{Representative: c.policy, Sources: []string{"paper", "wire"}}
+		reps := dd.chooseReps(id2cluster, info)
+		if reps["b"] != c.want {
+			t.Errorf("%s: representative %q, want %q", c.policy, reps["b"], c.want)
+		}
+		if _, ok := reps["z"]; ok {
+			t.Errorf("%s: singleton cluster got a representative", c.policy)
+		}
+	}
+}
+
+func TestCentroid(t *testing.T) {
+	info := map[string]docInfo{
+		"a": {sigs: []uint32{1, 2, 3, 4}},
+		"b": {sigs: []uint32{1, 2, 3, 5}},
+		"c": {sigs: []uint32{1, 2, 6, 5}},
+	}
+	if got := centroid([]string{"a", "b", "c"}, info); got != "b" {
+		t.Errorf("centroid = %q, want b", got)
+	}
+}
+
+func TestMakeRepPolicy(t *testing.T) {
+	if p, err := MakeRepPolicy("smallest-id"); err != nil || p != SMALLEST_ID {
+		t.Errorf("MakeRepPolicy(smallest-id) = %v, %v", p, err)
+	}
+	if _, err := MakeRepPolicy("biggest"); err == nil {
+		t.Error("MakeRepPolicy(biggest) should fail")
+	}
+}
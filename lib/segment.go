@@ -0,0 +1,147 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// A SegmentUnit is how a document is split into segments.
+type SegmentUnit int
+
+const (
+	// SEGMENT_PARAGRAPH segments are lines of text, separated by
+	// newlines.
+	SEGMENT_PARAGRAPH SegmentUnit = iota
+	// SEGMENT_SENTENCE segments end with sentence punctuation or a
+	// newline.
+	SEGMENT_SENTENCE
+	// SEGMENT_WINDOW segments are overlapping windows of words.
+	SEGMENT_WINDOW
+)
+
+var segmentNames = map[string]SegmentUnit{
+	"paragraph": SEGMENT_PARAGRAPH,
+	"sentence":  SEGMENT_SENTENCE,
+	"window":    SEGMENT_WINDOW,
+}
+
+func (u SegmentUnit) String() string {
+	for name, unit := range segmentNames {
+		if unit == u {
+			return name
+		}
+	}
+	return fmt.Sprintf("SegmentUnit(%d)", int(u))
+}
+
+// A Span is a piece of a document's text.  Segmenting gives byte offsets,
+// for slicing the text, and the output of Repeats and Passages gives
+// character offsets, converted by charSpans.
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// A Segmenter splits a document into spans.  Segments shorter than
+// MinChars characters, once white space is trimmed, are left out.  Windows are
+// Size words long and start every Step words, and the last window ends at
+// the last word.
+type Segmenter struct {
+	Unit     SegmentUnit
+	MinChars int
+	Size     int
+	Step     int
+}
+
+func MakeSegmenter(unit string, min_chars int) (Segmenter, error) {
+	u, ok := segmentNames[unit]
+	if !ok {
+		return Segmenter{}, fmt.Errorf("unknown segment unit %q", unit)
+	}
+	return Segmenter{Unit: u, MinChars: min_chars, Size: 50, Step: 25}, nil
+}
+
+var paragraph_re = regexp.MustCompile(`[^\n]+`)
+var word_re = regexp.MustCompile(`\S+`)
+
+// Segments returns the spans of the segments in the text, in order, with
+// leading and trailing white space trimmed off.
+func (sg Segmenter) Segments(text string) []Span {
+	if sg.Unit == SEGMENT_WINDOW {
+		return sg.windows(text)
+	}
+	re := paragraph_re
+	if sg.Unit == SEGMENT_SENTENCE {
+		re = sentence_re
+	}
+	var spans []Span
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		seg := text[loc[0]:loc[1]]
+		start := loc[0] + len(seg) - len(strings.TrimLeft(seg, " \t\r\n"))
+		end := loc[0] + len(strings.TrimRight(seg, " \t\r\n"))
+		if end <= start || utf8.RuneCountInString(text[start:end]) < sg.MinChars {
+			continue
+		}
+		spans = append(spans, Span{start, end})
+	}
+	return spans
+}
+
+// windows returns the spans of the word windows in the text.
+func (sg Segmenter) windows(text string) []Span {
+	words := word_re.FindAllStringIndex(text, -1)
+	if len(words) == 0 {
+		return nil
+	}
+	size, step := sg.Size, sg.Step
+	if size < 1 {
+		size = len(words)
+	}
+	if step < 1 {
+		step = size
+	}
+	var spans []Span
+	for i := 0; ; i += step {
+		last := i + size - 1
+		if last >= len(words) {
+			last = len(words) - 1
+			i = last - size + 1
+			if i < 0 {
+				i = 0
+			}
+		}
+		span := Span{words[i][0], words[last][1]}
+		if utf8.RuneCountInString(text[span.Start:span.End]) >= sg.MinChars && (len(spans) == 0 || span != spans[len(spans)-1]) {
+			spans = append(spans, span)
+		}
+		if last == len(words)-1 {
+			break
+		}
+	}
+	return spans
+}
+
+// charSpans converts spans of byte offsets in the text to character
+// offsets, counting through the text once.
+func charSpans(text string, spans []Span) []Span {
+	offsets := make([]int, 0, 2*len(spans))
+	for _, sp := range spans {
+		offsets = append(offsets, sp.Start, sp.End)
+	}
+	sort.Ints(offsets)
+	chars := make(map[int]int, len(offsets))
+	b, c := 0, 0
+	for _, o := range offsets {
+		c += utf8.RuneCountInString(text[b:o])
+		b = o
+		chars[o] = c
+	}
+	result := make([]Span, len(spans))
+	for i, sp := range spans {
+		result[i] = Span{chars[sp.Start], chars[sp.End]}
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestSegments(t *testing.T) {
+	text := "  First paragraph here.\n\nSecond one. It has two sentences.\nok\n"
+	seg := Segmenter{Unit: SEGMENT_PARAGRAPH, MinChars: 3}
+	got := seg.Segments(text)
+	want := []string{"First paragraph here.", "Second one. It has two sentences."}
+	if len(got) != len(want) {
+		t.Fatalf("Got %d paragraphs, want %d: %v", len(got), len(want), got)
+	}
+	for i, sp := range got {
+		if text[sp.Start:sp.End] != want[i] {
+			t.Errorf("Paragraph %d is %q, want %q", i, text[sp.Start:sp.End], want[i])
+		}
+	}
+
+	seg.Unit = SEGMENT_SENTENCE
+	if got := seg.Segments(text); len(got) != 3 || text[got[2].Start:got[2].End] != "It has two sentences." {
+		t.Errorf("Wrong sentences: %v", got)
+	}
+}
+
+func TestWindows(t *testing.T) {
+	text := "one two three four five six seven"
+	seg := Segmenter{Unit: SEGMENT_WINDOW, Size: 3, Step: 2}
+	want := []string{"one two three", "three four five", "five six seven"}
+	got := seg.Segments(text)
+	if len(got) != len(want) {
+		t.Fatalf("Got %d windows, want %d: %v", len(got), len(want), got)
+	}
+	for i, sp := range got {
+		if text[sp.Start:sp.End] != want[i] {
+			t.Errorf("Window %d is %q, want %q", i, text[sp.Start:sp.End], want[i])
+		}
+	}
+
+	// The last window is pulled back to end at the last word.
+	seg.Step = 3
+	got = seg.Segments(text)
+	if last := got[len(got)-1]; text[last.Start:last.End] != "five six seven" || len(got) != 3 {
+		t.Errorf("Wrong windows %v", got)
+	}
+
+	seg.Size = 10
+	if got := seg.Segments(text); len(got) != 1 || got[0] != (Span{0, len(text)}) {
+		t.Errorf("Short text gave windows %v", got)
+	}
+}
+
+func TestCharSpans(t *testing.T) {
+	// Each of these characters is three bytes in UTF-8.
+	text := "日本語\n東京都です\nok"
+	seg := Segmenter{Unit: SEGMENT_PARAGRAPH, MinChars: 3}
+	got := charSpans(text, seg.Segments(text))
+	want := []Span{{0, 3}, {4, 9}}
+	if len(got) != len(want) {
+		t.Fatalf("Got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Span %d is %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"hash"
 	"hash/fnv"
 	"fmt"
+	"log"
+	"regexp"
 	"strings"	
 )
 
@@ -11,56 +13,135 @@ const (
 	SHINGLE_LEN = 9
 )
 
-var hashfn hash.Hash32
+// A ShingleUnit is what a shingle is made of.
+type ShingleUnit int
 
-func fingerprint(s string) uint32 {
-	hashfn := fnv.New32()
-	hashfn.Write([]byte(s))
-	return hashfn.Sum32()
+const (
+	WORD ShingleUnit = iota
+	CHAR
+	SENTENCE
+)
+
+var unitNames = map[string]ShingleUnit{
+	"word":     WORD,
+	"char":     CHAR,
+	"sentence": SENTENCE,
 }
 
-func Shingle(s string) []uint32 {
-	if len(s) == 0 {
-		return nil
+func (u ShingleUnit) String() string {
+	for name, unit := range unitNames {
+		if unit == u {
+			return name
+		}
 	}
-	f := strings.Fields(s)
-	if len(f) == 0 {
-		return nil
+	return fmt.Sprintf("ShingleUnit(%d)", int(u))
+}
+
+// A Shingler breaks text into overlapping shingles of Size units.  Word
+// shingles are made from the words found by the Tokenizer.  Sentence
+// shingles need text that still has its sentence punctuation.
+type Shingler struct {
+	Size      int
+	Unit      ShingleUnit
+	Tokenizer Tokenizer
+}
+
+func MakeShingler(size int, unit string) (Shingler, error) {
+	u, ok := unitNames[unit]
+	if !ok {
+		return Shingler{}, fmt.Errorf("unknown shingle unit %q", unit)
 	}
-	num_shingles := len(f) - SHINGLE_LEN + 1
-	if num_shingles < 0 {
-		num_shingles = 1
+	if size < 1 {
+		return Shingler{}, fmt.Errorf("shingle size %d must be positive", size)
 	}
-	resmap := make(map[uint32]bool, num_shingles)
-	for i := 0; i < num_shingles; i++ {
-		var shingle string
-		if len(f) < SHINGLE_LEN {
-			shingle = strings.Join(f, " ")
-		} else {
-			shingle = strings.Join(f[i:i+SHINGLE_LEN], " ")
+	log.Printf("Shingles of %d %ss\n", size, u)
+	return Shingler{Size: size, Unit: u, Tokenizer: DefaultTokenizer}, nil
+}
+
+var sentence_re = regexp.MustCompile(`[^.!?。！？\n]+[.!?。！？]*`)
+
+// Strings returns the text of each shingle, in order and with repeats.
+func (sh Shingler) Strings(s string) []string {
+	switch sh.Unit {
+	case CHAR:
+		return charShingles(s, sh.Size)
+	case SENTENCE:
+		var f []string
+		for _, sent := range sentence_re.FindAllString(s, -1) {
+			if sent = strings.TrimSpace(sent); sent != "" {
+				f = append(f, sent)
+			}
 		}
+		return joinShingles(f, sh.Size)
+	default:
+		return joinShingles(sh.Tokenizer.Tokenize(s), sh.Size)
+	}
+}
+
+// Shingle returns the distinct fingerprints of the shingles in the text.
+func (sh Shingler) Shingle(s string) []uint32 {
+	shingles := sh.Strings(s)
+	resmap := make(map[uint32]bool, len(shingles))
+	for _, shingle := range shingles {
 		resmap[fingerprint(shingle)] = true
 	}
 	result := make([]uint32, len(resmap))
 	i := 0
-	for key, _ := range resmap {
+	for key := range resmap {
 		result[i] = key
 		i++
 	}
 	return result
 }
 
+// joinShingles makes shingles from runs of n units.  If there are fewer
+// than n units, they all go in one shingle.
+func joinShingles(f []string, n int) []string {
+	if len(f) == 0 {
+		return nil
+	}
+	if len(f) < n {
+		return []string{strings.Join(f, " ")}
+	}
+	result := make([]string, len(f)-n+1)
+	for i := range result {
+		result[i] = strings.Join(f[i:i+n], " ")
+	}
+	return result
+}
+
+var hashfn hash.Hash32
+
+func fingerprint(s string) uint32 {
+	hashfn := fnv.New32()
+	hashfn.Write([]byte(s))
+	return hashfn.Sum32()
+}
+
+func Shingle(s string) []uint32 {
+	return Shingler{Size: SHINGLE_LEN, Unit: WORD, Tokenizer: DefaultTokenizer}.Shingle(s)
+}
+
+// ShingleChars returns the distinct fingerprints of the SHINGLE_LEN
+// character shingles in the text.  Characters are Unicode code points, so
+// multi-byte UTF-8 text such as Arabic or Chinese is never split mid-rune.
 func ShingleChars(s string) []uint32 {
-	lens := len(s)
-	if lens < SHINGLE_LEN {
-		s = fmt.Sprintf(fmt.Sprintf("%%%ds", SHINGLE_LEN), s)
-		lens = len(s)
+	return Shingler{Size: SHINGLE_LEN, Unit: CHAR, Tokenizer: DefaultTokenizer}.Shingle(s)
+}
+
+// charShingles makes shingles from runs of n runes.  If there are fewer
+// than n runes, they all go in one shingle.
+func charShingles(s string, n int) []string {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return nil
+	}
+	if len(runes) < n {
+		return []string{s}
 	}
-	num_shingles := lens - SHINGLE_LEN
-	result := make([]uint32, num_shingles)
-	for i := 0; i < num_shingles; i++ {
-		shingle := s[i:i+SHINGLE_LEN]
-		result[i] = fingerprint(shingle)
+	result := make([]string, len(runes)-n+1)
+	for i := range result {
+		result[i] = string(runes[i:i+n])
 	}
 	return result
 }
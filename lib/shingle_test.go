@@ -0,0 +1,63 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestShinglerWords(t *testing.T) {
+	sh, err := MakeShingler(3, "word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := sh.Strings("a b c d e")
+	want := []string{"a b c", "b c d", "c d e"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Shingle %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+
+	if n := len(sh.Shingle("a b")); n != 1 {
+		t.Errorf("Short text should make one shingle, got %d", n)
+	}
+	if n := len(sh.Shingle("a b c a b c a b c")); n != 3 {
+		t.Errorf("Repeated shingles should be counted once, got %d", n)
+	}
+}
+
+func TestShinglerSentences(t *testing.T) {
+	sh := Shingler{Size: 2, Unit: SENTENCE}
+	got := sh.Strings("One fish.  Two fish!  Red fish? Blue fish")
+	if len(got) != 3 || got[0] != "One fish. Two fish!" || got[2] != "Red fish? Blue fish" {
+		t.Errorf("Unexpected sentence shingles %q", got)
+	}
+}
+
+func TestShinglerUnit(t *testing.T) {
+	if _, err := MakeShingler(9, "paragraph"); err == nil {
+		t.Error("Expected an error for an unknown unit")
+	}
+}
+
+func TestShingleCharsUnicode(t *testing.T) {
+	sh := Shingler{Size: 3, Unit: CHAR}
+	got := sh.Strings("سلام دنیا")
+	if len(got) != 7 {
+		t.Fatalf("Expected 7 shingles, got %d: %q", len(got), got)
+	}
+	if got[0] != "سلا" || got[6] != "نیا" {
+		t.Errorf("Unexpected shingles %q", got)
+	}
+
+	got = sh.Strings("你好")
+	if len(got) != 1 || got[0] != "你好" {
+		t.Errorf("Short text should make one shingle, got %q", got)
+	}
+
+	if n := len(ShingleChars("abababababababab")); n != 2 {
+		t.Errorf("Repeated shingles should be counted once, got %d", n)
+	}
+}
@@ -0,0 +1,190 @@
+package lib
+
+import (
+	"fmt"
+	"log"
+	"sort"
+)
+
+// A SplitMethod says how a cluster that has chained together documents
+// too far apart is broken up.  Distances are one minus the Jaccard
+// similarity estimated from the signatures.
+type SplitMethod int
+
+const (
+	// COMPLETE is complete-link clustering: every pair of documents in a
+	// cluster is within the maximum diameter.
+	COMPLETE SplitMethod = iota
+	// CENTER takes the first document not yet placed as a center, and
+	// puts every other unplaced document within the maximum diameter of
+	// it in its cluster.  The maximum is then really a radius, and two
+	// documents in a cluster can be up to twice it apart, but this takes
+	// linear rather than quadratic time for each center.
+	CENTER
+)
+
+var splitNames = map[string]SplitMethod{
+	"center":   CENTER,
+	"complete": COMPLETE,
+}
+
+func (m SplitMethod) String() string {
+	for name, method := range splitNames {
+		if method == m {
+			return name
+		}
+	}
+	return fmt.Sprintf("SplitMethod(%d)", int(m))
+}
+
+func MakeSplitMethod(name string) (SplitMethod, error) {
+	m, ok := splitNames[name]
+	if !ok {
+		return COMPLETE, fmt.Errorf("unknown split method %q", name)
+	}
+	return m, nil
+}
+
+// COMPLETE_LINK_MAX is the largest cluster that is split by complete-link
+// clustering, which takes time cubic in the size of the cluster in the
+// worst case.  Larger clusters are split around centers instead.
+const COMPLETE_LINK_MAX = 2000
+
+// tooWide says whether a cluster needs splitting.  With pairwise set, that
+// is whether any two of the signatures are further apart than the maximum
+// diameter.  Otherwise it is whether any is that far from the first,
+// which is all that splitting around centers ensures, and which takes
+// linear rather than quadratic time for huge clusters.
+func tooWide(sigs [][]uint32, diameter float64, pairwise bool) bool {
+	last := 1
+	if pairwise {
+		last = len(sigs)
+	}
+	for i := 0; i < last; i++ {
+		for j := i + 1; j < len(sigs); j++ {
+			if 1-EstimateJaccard(sigs[i], sigs[j]) > diameter {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// centerSplit returns, for each signature, the index of the center of its
+// cluster.
+func centerSplit(sigs [][]uint32, diameter float64) []int {
+	center := make([]int, len(sigs))
+	for i := range center {
+		center[i] = -1
+	}
+	for i := range sigs {
+		if center[i] >= 0 {
+			continue
+		}
+		center[i] = i
+		for j := i + 1; j < len(sigs); j++ {
+			if center[j] < 0 && 1-EstimateJaccard(sigs[i], sigs[j]) <= diameter {
+				center[j] = i
+			}
+		}
+	}
+	return center
+}
+
+// completeSplit returns, for each signature, the index of the first
+// signature in its cluster.  Pairs are taken closest first, and their
+// clusters merged if every pair across them is within the diameter.
+func completeSplit(sigs [][]uint32, diameter float64) []int {
+	n := len(sigs)
+	dist := make([]float64, n*n)
+	type edge struct{ i, j int }
+	var edges []edge
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			d := 1 - EstimateJaccard(sigs[i], sigs[j])
+			dist[i*n+j], dist[j*n+i] = d, d
+			if d <= diameter {
+				edges = append(edges, edge{i, j})
+			}
+		}
+	}
+	sort.SliceStable(edges, func(a, b int) bool {
+		return dist[edges[a].i*n+edges[a].j] < dist[edges[b].i*n+edges[b].j]
+	})
+
+	// first[i] is the first member of i's cluster, and groups holds the
+	// members of each cluster by its first member.
+	first := make([]int, n)
+	groups := make(map[int][]int, n)
+	for i := range first {
+		first[i] = i
+		groups[i] = []int{i}
+	}
+	for _, e := range edges {
+		a, b := first[e.i], first[e.j]
+		if a == b {
+			continue
+		}
+		fits := true
+		for _, x := range groups[a] {
+			for _, y := range groups[b] {
+				if dist[x*n+y] > diameter {
+					fits = false
+					break
+				}
+			}
+			if !fits {
+				break
+			}
+		}
+		if !fits {
+			continue
+		}
+		if b < a {
+			a, b = b, a
+		}
+		for _, y := range groups[b] {
+			first[y] = a
+		}
+		groups[a] = append(groups[a], groups[b]...)
+		delete(groups, b)
+	}
+	return first
+}
+
+// splitClusters breaks up the clusters that are wider than MaxDiameter,
+// reassigning their documents in id2cluster.  members lists the documents
+// of each cluster in input order, and a new cluster is named by its first
+// document.  It returns the number of clusters split.
+func (dd Deduper) splitClusters(members map[string][]string, sigOf func(string) []uint32, id2cluster map[string]string) int {
+	split := 0
+	for _, ids := range members {
+		if len(ids) < 2 {
+			continue
+		}
+		sigs := make([][]uint32, len(ids))
+		for i, id := range ids {
+			sigs[i] = sigOf(id)
+		}
+		complete := dd.SplitMethod == COMPLETE && len(ids) <= COMPLETE_LINK_MAX
+		if dd.SplitMethod == COMPLETE && !complete {
+			log.Printf("Cluster %s has %d documents, over %d, so it is split around centers\n",
+				ids[0], len(ids), COMPLETE_LINK_MAX)
+		}
+		if !tooWide(sigs, dd.MaxDiameter, complete) {
+			continue
+		}
+		var first []int
+		if complete {
+			first = completeSplit(sigs, dd.MaxDiameter)
+		} else {
+			first = centerSplit(sigs, dd.MaxDiameter)
+		}
+		for i, id := range ids {
+			id2cluster[id] = ids[first[i]]
+		}
+		split++
+	}
+	log.Println(split, "clusters split by", dd.SplitMethod, "with diameter", dd.MaxDiameter)
+	return split
+}
@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"testing"
+)
+
+// A chain: a is close to b, b is close to c, but a and c are far apart.
+var chain = [][]uint32{
+	{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+	{1, 2, 3, 4, 5, 6, 0, 0, 0, 0},
+	{0, 0, 0, 4, 5, 6, 0, 0, 0, 0},
+}
+
+func sameInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSplit(t *testing.T) {
+	for _, pairwise := range []bool{true, false} {
+		if !tooWide(chain, 0.6, pairwise) {
+			t.Error("Chain should be too wide for diameter 0.6")
+		}
+		if tooWide(chain, 0.7, pairwise) {
+			t.Error("Chain should fit in diameter 0.7")
+		}
+	}
+	// Both are close to the first, but not to each other.
+	star := [][]uint32{
+		{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		{1, 2, 3, 4, 5, 6, 0, 0, 0, 0},
+		{0, 0, 0, 0, 5, 6, 7, 8, 9, 10},
+	}
+	if !tooWide(star, 0.6, true) || tooWide(star, 0.6, false) {
+		t.Error("Star should only be too wide pairwise")
+	}
+	if got := centerSplit(chain, 0.6); !sameInts(got, []int{0, 0, 2}) {
+		t.Errorf("centerSplit = %v, want [0 0 2]", got)
+	}
+	if got := completeSplit(chain, 0.6); !sameInts(got, []int{0, 1, 1}) {
+		t.Errorf("completeSplit = %v, want [0 1 1]", got)
+	}
+}
+
+func TestSplitClusters(t *testing.T) {
+	sigs := map[string][]uint32{"a": chain[0], "b": chain[1], "c": chain[2], "d": chain[0]}
+	id2cluster := map[string]string{"a": "a", "b": "a", "c": "a", "d": "d"}
+	members := map[string][]string{"a": {"a", "b", "c"}, "d": {"d"}}
+	dd := Deduper{MaxDiameter: 0.6, SplitMethod: COMPLETE}
+	n := dd.splitClusters(members, func(id string) []uint32 { return sigs[id] }, id2cluster)
+	if n != 1 {
+		t.Errorf("Split %d clusters, want 1", n)
+	}
+	want := map[string]string{"a": "a", "b": "b", "c": "b", "d": "d"}
+	for id, c := range want {
+		if id2cluster[id] != c {
+			t.Errorf("%s is in cluster %s, want %s", id, id2cluster[id], c)
+		}
+	}
+}
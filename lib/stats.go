@@ -0,0 +1,200 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// STATS_LARGEST is how many of the largest clusters the statistics
+	// list, and STATS_TITLES how many document names are shown for each.
+	STATS_LARGEST = 10
+	STATS_TITLES  = 3
+)
+
+// Stats summarizes a Dedupe run.  Removable is the number of documents
+// that are not cluster representatives, which a deduplicated collection
+// leaves out.  NearDuplicateClusters only counts clusters that MinHash put
+// together, and not those made only of exact duplicates.
+type Stats struct {
+	// Threshold is set when clustering at several thresholds, with
+	// statistics for each.
+	Threshold             float64         `json:"threshold,omitempty"`
+	Documents             int             `json:"documents"`
+	ExactDuplicates       int             `json:"exact_duplicates"`
+	Clusters              int             `json:"clusters"`
+	NearDuplicateClusters int             `json:"near_duplicate_clusters"`
+	Removable             int             `json:"removable"`
+	RemovableFraction     float64         `json:"removable_fraction"`
+	Histogram             []SizeBin       `json:"histogram"`
+	Largest               []ClusterSample `json:"largest"`
+	Phases                []PhaseTime     `json:"phases"`
+}
+
+// A SizeBin counts the clusters with between Min and Max documents, and
+// the documents in them.
+type SizeBin struct {
+	Min       int `json:"min"`
+	Max       int `json:"max"`
+	Clusters  int `json:"clusters"`
+	Documents int `json:"documents"`
+}
+
+// A ClusterSample is one of the largest clusters, with the names of a few
+// of its documents.
+type ClusterSample struct {
+	Id     string   `json:"id"`
+	Size   int      `json:"size"`
+	Titles []string `json:"titles"`
+}
+
+// PhaseTime is how long a phase of the run took.
+type PhaseTime struct {
+	Phase   string  `json:"phase"`
+	Seconds float64 `json:"seconds"`
+}
+
+// timePhase records the time since start as the named phase.
+func (s *Stats) timePhase(phase string, start time.Time) {
+	s.Phases = append(s.Phases, PhaseTime{phase, time.Since(start).Seconds()})
+}
+
+// count fills in the cluster counts, the histogram and the largest
+// clusters from the cluster assignments, out of doccount documents read.
+// id2exact lists the exact duplicates, which don't make a cluster a
+// near-duplicate cluster.  Cluster sizes are binned by powers of two.  The
+// sample titles are filled in later, by sample.
+func (s *Stats) count(doccount int, id2cluster, id2exact, reps map[string]string) {
+	sizes := make(map[string]int)
+	near := make(map[string]bool)
+	for id, cluster := range id2cluster {
+		sizes[cluster]++
+		if _, exact := id2exact[id]; !exact && id != cluster {
+			near[cluster] = true
+		}
+	}
+	s.Documents = doccount
+	s.Clusters = len(sizes)
+	s.Removable = s.Documents - s.Clusters
+	if s.Documents > 0 {
+		s.RemovableFraction = float64(s.Removable) / float64(s.Documents)
+	}
+
+	var clusters []string
+	s.Histogram = nil
+	for cluster, size := range sizes {
+		if size > 1 {
+			clusters = append(clusters, cluster)
+		}
+		if near[cluster] {
+			s.NearDuplicateClusters++
+		}
+		bin := 0
+		for 1<<uint(bin+1) <= size {
+			bin++
+		}
+		for len(s.Histogram) <= bin {
+			lo := 1 << uint(len(s.Histogram))
+			s.Histogram = append(s.Histogram, SizeBin{Min: lo, Max: 2*lo - 1})
+		}
+		s.Histogram[bin].Clusters++
+		s.Histogram[bin].Documents += size
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if sizes[clusters[i]] != sizes[clusters[j]] {
+			return sizes[clusters[i]] > sizes[clusters[j]]
+		}
+		return clusters[i] < clusters[j]
+	})
+	if len(clusters) > STATS_LARGEST {
+		clusters = clusters[:STATS_LARGEST]
+	}
+	s.Largest = make([]ClusterSample, len(clusters))
+	for i, cluster := range clusters {
+		rep, ok := reps[cluster]
+		if !ok {
+			rep = cluster
+		}
+		s.Largest[i] = ClusterSample{Id: rep, Size: sizes[cluster]}
+	}
+}
+
+// sample adds the name of a document in a cluster named by its
+// representative, if it is one of the largest and needs more titles.
+func (s *Stats) sample(rep, name string) {
+	for i := range s.Largest {
+		c := &s.Largest[i]
+		if c.Id == rep && len(c.Titles) < STATS_TITLES {
+			c.Titles = append(c.Titles, name)
+		}
+	}
+}
+
+// Print writes the statistics as a table.
+func (s *Stats) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	if s.Threshold > 0 {
+		fmt.Fprintf(tw, "Threshold\t%g\n", s.Threshold)
+	}
+	fmt.Fprintf(tw, "Documents read\t%d\n", s.Documents)
+	fmt.Fprintf(tw, "Exact duplicates\t%d\n", s.ExactDuplicates)
+	fmt.Fprintf(tw, "Clusters\t%d\n", s.Clusters)
+	fmt.Fprintf(tw, "Near-duplicate clusters\t%d\n", s.NearDuplicateClusters)
+	fmt.Fprintf(tw, "Removable documents\t%d (%.2f%%)\n", s.Removable, 100*s.RemovableFraction)
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "Cluster size\tClusters\tDocuments")
+	for _, bin := range s.Histogram {
+		size := fmt.Sprint(bin.Min)
+		if bin.Max > bin.Min {
+			size = fmt.Sprintf("%d-%d", bin.Min, bin.Max)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", size, bin.Clusters, bin.Documents)
+	}
+	fmt.Fprintln(tw)
+
+	if len(s.Largest) > 0 {
+		fmt.Fprintln(tw, "Largest clusters\tSize\tSample titles")
+		for _, c := range s.Largest {
+			if len(c.Titles) == 0 {
+				fmt.Fprintf(tw, "%s\t%d\t\n", c.Id, c.Size)
+			}
+			for i, title := range c.Titles {
+				if i == 0 {
+					fmt.Fprintf(tw, "%s\t%d\t%s\n", c.Id, c.Size, title)
+				} else {
+					fmt.Fprintf(tw, "\t\t%s\n", title)
+				}
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+
+	if len(s.Phases) > 0 {
+		fmt.Fprintln(tw, "Phase\tSeconds")
+		for _, p := range s.Phases {
+			fmt.Fprintf(tw, "%s\t%.2f\n", p.Phase, p.Seconds)
+		}
+	}
+	tw.Flush()
+}
+
+// WriteJSON writes the statistics to a file as JSON.
+func (s *Stats) WriteJSON(filename string) error {
+	return writeJSON(filename, s)
+}
+
+// writeJSON writes a value to a file as indented JSON.
+func writeJSON(filename string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(data, '\n'), 0644)
+}
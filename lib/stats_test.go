@@ -0,0 +1,41 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestStatsCount(t *testing.T) {
+	id2cluster := map[string]string{
+		"a": "a", "b": "a", "c": "a", "d": "a", "e": "a",
+		"f": "f", "g": "f",
+		"h": "h",
+	}
+	// f and g are exact duplicates, so their cluster isn't a near-duplicate
+	// cluster.
+	id2exact := map[string]string{"g": "f", "e": "a"}
+	var s Stats
+	s.count(8, id2cluster, id2exact, map[string]string{"a": "c"})
+
+	if s.Documents != 8 || s.Clusters != 3 || s.NearDuplicateClusters != 1 || s.Removable != 5 {
+		t.Errorf("Wrong counts: %+v", s)
+	}
+	want := []SizeBin{{1, 1, 1, 1}, {2, 3, 1, 2}, {4, 7, 1, 5}}
+	if len(s.Histogram) != len(want) {
+		t.Fatalf("Histogram %v, want %v", s.Histogram, want)
+	}
+	for i := range want {
+		if s.Histogram[i] != want[i] {
+			t.Errorf("Histogram bin %d is %v, want %v", i, s.Histogram[i], want[i])
+		}
+	}
+	if len(s.Largest) != 2 || s.Largest[0].Id != "c" || s.Largest[0].Size != 5 {
+		t.Errorf("Largest clusters %v, want c first with 5", s.Largest)
+	}
+
+	for i := 0; i < 5; i++ {
+		s.sample("c", "title")
+	}
+	if len(s.Largest[0].Titles) != STATS_TITLES {
+		t.Errorf("Got %d sample titles, want %d", len(s.Largest[0].Titles), STATS_TITLES)
+	}
+}
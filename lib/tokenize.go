@@ -0,0 +1,75 @@
+package lib
+
+import (
+	"strings"
+	"unicode"
+)
+
+// unsegmented lists the scripts that are written without spaces between
+// words.  Korean Hangul is written with spaces and so is not included.
+var unsegmented = []*unicode.RangeTable{
+	unicode.Han,
+	unicode.Hiragana,
+	unicode.Katakana,
+	unicode.Thai,
+	unicode.Lao,
+	unicode.Khmer,
+	unicode.Myanmar,
+	unicode.Tibetan,
+}
+
+// IsUnsegmented reports whether r belongs to a script that is written
+// without spaces between words.
+func IsUnsegmented(r rune) bool {
+	return unicode.IsOneOf(unsegmented, r)
+}
+
+// A Tokenizer splits text into words.  Text is split on white space, and
+// then runs of characters from scripts written without spaces, such as
+// Chinese, Japanese and Thai, are broken into overlapping character
+// n-grams of NGram characters, since there is no dictionary to segment
+// them into words.  With NGram less than one, text is only split on white
+// space.
+type Tokenizer struct {
+	NGram int
+}
+
+// DefaultTokenizer is the Tokenizer used unless another is asked for.  It
+// breaks unsegmented scripts into character bigrams.
+var DefaultTokenizer = Tokenizer{NGram: 2}
+
+func (t Tokenizer) Tokenize(s string) []string {
+	fields := strings.Fields(s)
+	if t.NGram < 1 {
+		return fields
+	}
+	result := make([]string, 0, len(fields))
+	for _, f := range fields {
+		result = t.splitScripts(f, result)
+	}
+	return result
+}
+
+// splitScripts appends the tokens in a space-free field to result.  Runs of
+// unsegmented characters become n-grams, and other runs are kept whole.
+func (t Tokenizer) splitScripts(f string, result []string) []string {
+	runes := []rune(f)
+	start := 0
+	for start < len(runes) {
+		unseg := IsUnsegmented(runes[start])
+		end := start + 1
+		for end < len(runes) && IsUnsegmented(runes[end]) == unseg {
+			end++
+		}
+		run := runes[start:end]
+		if !unseg || len(run) <= t.NGram {
+			result = append(result, string(run))
+		} else {
+			for i := 0; i+t.NGram <= len(run); i++ {
+				result = append(result, string(run[i:i+t.NGram]))
+			}
+		}
+		start = end
+	}
+	return result
+}
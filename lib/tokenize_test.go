@@ -0,0 +1,32 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tok := Tokenizer{2}
+	cases := []struct {
+		text string
+		want []string
+	}{
+		{"the quick fox", []string{"the", "quick", "fox"}},
+		{"東京都に住む", []string{"東京", "京都", "都に", "に住", "住む"}},
+		{"ภาษาไทย", []string{"ภา", "าษ", "ษา", "าไ", "ไท", "ทย"}},
+		{"iPhone发布会 today", []string{"iPhone", "发布", "布会", "today"}},
+		{"한국어 문장", []string{"한국어", "문장"}},
+	}
+	for _, c := range cases {
+		got := tok.Tokenize(c.text)
+		if len(got) != len(c.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", c.text, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("Tokenize(%q) = %q, want %q", c.text, got, c.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package lib
+
+import (
+	"bufio"
+	"regexp"
+	"strings"
+)
+
+// A TrecReader reads documents in the SGML format used by most TREC
+// collections, such as TREC disks 4 and 5 and AQUAINT:
+//
+//	<DOC>
+//	<DOCNO> FT911-3 </DOCNO>
+//	<HEADLINE> ... </HEADLINE>
+//	<TEXT> ... </TEXT>
+//	</DOC>
+//
+// The tags holding the document id, title and text are configurable.  The
+// text of every text tag in a document is used, with any markup inside
+// removed.  If there is no title tag, the start of the text is used as the
+// name of the document.  Collections date documents in different ways, so
+// documents only have dates if SetDate says where to find them.  With
+// ExtractHTML, the text tags are taken to hold web pages, and their main
+// text is pulled out with ExtractText.
+type TrecReader struct {
+	ExtractHTML bool
+
+	open_re     *regexp.Regexp
+	close_re    *regexp.Regexp
+	id_re       *regexp.Regexp
+	title_re    *regexp.Regexp
+	text_res    []*regexp.Regexp
+	date_re     *regexp.Regexp
+	date_layout string
+}
+
+func MakeTrecReader(doc, id, title string, text []string) *TrecReader {
+	t := new(TrecReader)
+	doc = regexp.QuoteMeta(doc)
+	t.open_re = regexp.MustCompile(`(?i)<` + doc + `[\s>]`)
+	t.close_re = regexp.MustCompile(`(?i)</` + doc + `\s*>`)
+	t.id_re = trecTagRe(id)
+	if title != "" {
+		t.title_re = trecTagRe(title)
+	}
+	for _, tag := range text {
+		t.text_res = append(t.text_res, trecTagRe(tag))
+	}
+	return t
+}
+
+// SetDate reads document dates from the tag, which are parsed with the
+// time.Parse layout after any markup is removed.
+func (t *TrecReader) SetDate(tag, layout string) {
+	t.date_re = trecTagRe(tag)
+	t.date_layout = layout
+}
+
+func trecTagRe(tag string) *regexp.Regexp {
+	tag = regexp.QuoteMeta(tag)
+	return regexp.MustCompile(`(?is)<` + tag + `(?:\s[^>]*)?>(.*?)</` + tag + `\s*>`)
+}
+
+// Read sends each document in the stream to the channel, and closes the
+// channel at the end.  Documents are found by the offsets of their open and
+// close tags, so several can share a line, as some bundles have them.
+func (t *TrecReader) Read(reader *bufio.Reader, c chan Document) {
+	var buf strings.Builder
+	in_doc := false
+	for {
+		line, err := reader.ReadString('\n')
+		rest := line
+		for rest != "" {
+			if !in_doc {
+				loc := t.open_re.FindStringIndex(rest)
+				if loc == nil {
+					break
+				}
+				in_doc = true
+				rest = rest[loc[0]:]
+			}
+			loc := t.close_re.FindStringIndex(rest)
+			if loc == nil {
+				buf.WriteString(rest)
+				break
+			}
+			buf.WriteString(rest[:loc[1]])
+			c <- t.parse(buf.String())
+			buf.Reset()
+			in_doc = false
+			rest = rest[loc[1]:]
+		}
+		if err != nil {
+			break
+		}
+	}
+	close(c)
+}
+
+func (t *TrecReader) parse(record string) Document {
+	var doc Document
+	doc.Raw = record
+	if m := t.id_re.FindStringSubmatch(record); m != nil {
+		doc.Id = strings.TrimSpace(m[1])
+	}
+
+	var text strings.Builder
+	for _, re := range t.text_res {
+		for _, m := range re.FindAllStringSubmatch(record, -1) {
+			if t.ExtractHTML {
+				text.WriteString(ExtractText(m[1]))
+			} else {
+				text.WriteString(html_re.ReplaceAllLiteralString(m[1], " "))
+			}
+			text.WriteRune(' ')
+		}
+	}
+	doc.Text = text.String()
+
+	var title string
+	if t.title_re != nil {
+		if m := t.title_re.FindStringSubmatch(record); m != nil {
+			title = html_re.ReplaceAllLiteralString(m[1], " ")
+		}
+	}
+	doc.Name = documentName(title, doc.Text)
+
+	if t.date_re != nil {
+		if m := t.date_re.FindStringSubmatch(record); m != nil {
+			doc.Date = parseDate(html_re.ReplaceAllLiteralString(m[1], ""), t.date_layout)
+		}
+	}
+	return doc
+}
@@ -0,0 +1,102 @@
+package lib
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestTrecReader(t *testing.T) {
+	input := `<DOC>
+<DOCNO> FT911-1 </DOCNO>
+<HEADLINE>
+FT  14 MAY 91 / Markets rally
+</HEADLINE>
+<TEXT>
+<P>Shares rose sharply.</P>
+</TEXT>
+</DOC>
+<DOC><DOCNO>FT911-2</DOCNO><TEXT>One line document with no headline at all.</TEXT><TEXT>Second part.</TEXT></DOC>
+`
+	tr := MakeTrecReader("DOC", "DOCNO", "HEADLINE", []string{"TEXT"})
+	c := make(chan Document)
+	go tr.Read(bufio.NewReader(strings.NewReader(input)), c)
+	var docs []Document
+	for doc := range c {
+		docs = append(docs, doc)
+	}
+
+	if len(docs) != 2 {
+		t.Fatalf("Expected 2 documents, got %d", len(docs))
+	}
+	if docs[0].Id != "FT911-1" || docs[0].Name != "FT 14 MAY 91 / Markets rally" {
+		t.Errorf("Bad first document %q %q", docs[0].Id, docs[0].Name)
+	}
+	if strings.Join(strings.Fields(docs[0].Text), " ") != "Shares rose sharply." {
+		t.Errorf("Bad first text %q", docs[0].Text)
+	}
+	if docs[1].Id != "FT911-2" || !strings.Contains(docs[1].Text, "Second part.") {
+		t.Errorf("Bad second document %+v", docs[1])
+	}
+	if docs[1].Name != "One line document with no headline at all. Second" {
+		t.Errorf("Bad second name %q", docs[1].Name)
+	}
+}
+
+func TestTrecReaderSharedLines(t *testing.T) {
+	input := `<DOC>
+<DOCNO>A</DOCNO>
+<TEXT>First.</TEXT>
+</DOC><DOC>
+<DOCNO>B</DOCNO>
+<TEXT>Second.</TEXT>
+</DOC>
+<DOC><DOCNO>C</DOCNO><TEXT>Third.</TEXT></DOC><DOC><DOCNO>D</DOCNO><TEXT>Fourth.</TEXT></DOC>
+`
+	tr := MakeTrecReader("DOC", "DOCNO", "", []string{"TEXT"})
+	c := make(chan Document)
+	go tr.Read(bufio.NewReader(strings.NewReader(input)), c)
+	var ids []string
+	for doc := range c {
+		ids = append(ids, doc.Id)
+		if strings.Count(doc.Raw, "<DOC>") != 1 {
+			t.Errorf("Document %s has raw text %q", doc.Id, doc.Raw)
+		}
+	}
+	if strings.Join(ids, " ") != "A B C D" {
+		t.Errorf("Read documents %v, want A B C D", ids)
+	}
+}
+
+func TestTrecReaderDate(t *testing.T) {
+	input := "<DOC><DOCNO>A</DOCNO><DATE><P>19910514</P></DATE><TEXT>Dated.</TEXT></DOC>\n" +
+		"<DOC><DOCNO>B</DOCNO><TEXT>Undated.</TEXT></DOC>\n"
+	tr := MakeTrecReader("DOC", "DOCNO", "", []string{"TEXT"})
+	tr.SetDate("DATE", "20060102")
+	c := make(chan Document)
+	go tr.Read(bufio.NewReader(strings.NewReader(input)), c)
+	var docs []Document
+	for doc := range c {
+		docs = append(docs, doc)
+	}
+	if len(docs) != 2 || docs[0].Date.Format("2006-01-02") != "1991-05-14" || !docs[1].Date.IsZero() {
+		t.Errorf("Bad dates %+v", docs)
+	}
+}
+
+func TestTrecReaderExtractHTML(t *testing.T) {
+	input := `<DOC><DOCNO>web-1</DOCNO><TEXT><html><nav><a href="/">Home</a></nav>
+<p>The main story of the page is in this paragraph.</p>
+<script>var x = 1;</script></html></TEXT></DOC>
+`
+	tr := MakeTrecReader("DOC", "DOCNO", "", []string{"TEXT"})
+	tr.ExtractHTML = true
+	c := make(chan Document)
+	go tr.Read(bufio.NewReader(strings.NewReader(input)), c)
+	doc := <-c
+	for range c {
+	}
+	if got := strings.TrimSpace(doc.Text); got != "The main story of the page is in this paragraph." {
+		t.Errorf("Got text %q, want only the paragraph", got)
+	}
+}
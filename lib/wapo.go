@@ -0,0 +1,70 @@
+package lib
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// WapoFields says which "contents" entries make up the text of a
+// Washington Post article.  Types are matched against either the entry's
+// type or its type/subtype.  Only text entries are used, limited to those
+// in types if there are any, and leaving out those in skip.  The title
+// entry is included whenever title is set, even if types doesn't list
+// it, unless skip does.  With ExtractHTML, the main text of HTML
+// entries is pulled out with ExtractText.
+type WapoFields struct {
+	ExtractHTML bool
+
+	types map[string]bool
+	skip  map[string]bool
+	title bool
+}
+
+func MakeWapoFields(types, skip []string, title bool) *WapoFields {
+	f := new(WapoFields)
+	f.types = make(map[string]bool)
+	for _, t := range types {
+		f.types[t] = true
+	}
+	f.skip = make(map[string]bool)
+	for _, t := range skip {
+		f.skip[t] = true
+	}
+	f.title = title
+	return f
+}
+
+// Wants reports whether a contents entry is part of the text.
+func (f *WapoFields) Wants(entry gjson.Result) bool {
+	if !strings.HasPrefix(entry.Get("mime").String(), "text/") {
+		return false
+	}
+	etype := entry.Get("type").String()
+	subtype := etype + "/" + entry.Get("subtype").String()
+	if etype == "title" {
+		return f.title && !f.skip[etype] && !f.skip[subtype]
+	}
+	if f.skip[etype] || f.skip[subtype] {
+		return false
+	}
+	return len(f.types) == 0 || f.types[etype] || f.types[subtype]
+}
+
+// Text joins the text of the wanted contents entries of the article, one
+// to a line, so that paragraphs stay separate for passages and repeats.
+func (f *WapoFields) Text(article gjson.Result) string {
+	var textbuf strings.Builder
+	article.Get("contents").ForEach(func(key, val gjson.Result) bool {
+		if f.Wants(val) {
+			content := val.Get("content").String()
+			if f.ExtractHTML && val.Get("mime").String() == "text/html" {
+				content = ExtractText(content)
+			}
+			textbuf.WriteString(content)
+			textbuf.WriteRune('\n')
+		}
+		return true
+	})
+	return textbuf.String()
+}
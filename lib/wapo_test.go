@@ -0,0 +1,43 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+const wapoArticle = `{"id": "a1", "contents": [
+	{"type": "title", "mime": "text/plain", "content": "Title"},
+	{"type": "byline", "mime": "text/plain", "content": "By Someone"},
+	{"type": "sanitized_html", "subtype": "paragraph", "mime": "text/html", "content": "<p>First paragraph.</p>"},
+	{"type": "image", "mime": "image/jpeg", "content": "photo.jpg"}
+]}`
+
+func TestWapoFields(t *testing.T) {
+	article := gjson.Parse(wapoArticle)
+	cases := []struct {
+		types, skip []string
+		title       bool
+		want        string
+	}{
+		{nil, nil, true, "Title\nBy Someone\n<p>First paragraph.</p>\n"},
+		{nil, nil, false, "By Someone\n<p>First paragraph.</p>\n"},
+		{nil, []string{"byline"}, true, "Title\n<p>First paragraph.</p>\n"},
+		// The title is included even though the types don't list it.
+		{[]string{"sanitized_html/paragraph"}, nil, true, "Title\n<p>First paragraph.</p>\n"},
+		{[]string{"sanitized_html"}, nil, false, "<p>First paragraph.</p>\n"},
+		{[]string{"sanitized_html"}, []string{"title"}, true, "<p>First paragraph.</p>\n"},
+	}
+	for _, c := range cases {
+		f := MakeWapoFields(c.types, c.skip, c.title)
+		if got := f.Text(article); got != c.want {
+			t.Errorf("Types %v, skip %v, title %v: got %q, want %q", c.types, c.skip, c.title, got, c.want)
+		}
+	}
+
+	f := MakeWapoFields([]string{"sanitized_html"}, nil, false)
+	f.ExtractHTML = true
+	if got := f.Text(article); got != "First paragraph.\n" {
+		t.Errorf("Extracting HTML got %q", got)
+	}
+}
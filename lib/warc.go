@@ -0,0 +1,181 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A WarcReader reads web pages from WARC files, as written by web crawlers,
+// and Common Crawl WET files, which hold the text already extracted from
+// each page.  From WARC files it reads response records, strips the HTTP
+// headers, and extracts the main text from HTML pages; from WET files it
+// reads conversion records.  Other records, and responses that aren't
+// text, are skipped.
+//
+// The document id is the WARC-Record-ID, or with UseURI the
+// WARC-Target-URI.  The name is the page title if there is one, or else the
+// target URI.  The date is the WARC-Date, and the source is the host of the
+// target URI.
+type WarcReader struct {
+	UseURI bool
+}
+
+// WARC_MAX_RECORD is the largest record content that will be read, so that a
+// corrupt Content-Length can't exhaust memory.
+const WARC_MAX_RECORD = 1 << 30
+
+type warcRecord struct {
+	header  map[string]string
+	content []byte
+	raw     strings.Builder
+}
+
+var html_title_re = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title\s*>`)
+
+// Read sends each text record in the stream to the channel, and closes the
+// channel at the end.
+func (w WarcReader) Read(reader *bufio.Reader, c chan Document) {
+	for {
+		rec, err := readWarcRecord(reader)
+		if err != nil {
+			if err != io.EOF {
+				log.Println("Error reading WARC record:", err)
+			}
+			break
+		}
+
+		var doc Document
+		uri := rec.header["warc-target-uri"]
+		switch rec.header["warc-type"] {
+		case "response":
+			text, title, ok := httpText(rec.content)
+			if !ok {
+				continue
+			}
+			doc.Text, doc.Name = text, title
+		case "conversion":
+			doc.Text = string(rec.content)
+		default:
+			continue
+		}
+
+		doc.Id = rec.header["warc-record-id"]
+		doc.Raw = rec.raw.String()
+		if w.UseURI && uri != "" {
+			doc.Id = uri
+		}
+		if strings.TrimSpace(doc.Name) == "" {
+			doc.Name = uri
+		}
+		if date, err := time.Parse(time.RFC3339, rec.header["warc-date"]); err == nil {
+			doc.Date = date
+		}
+		if u, err := url.Parse(uri); err == nil {
+			doc.Source = u.Hostname()
+		}
+		doc.Name = strings.Join(strings.Fields(doc.Name), " ")
+		c <- doc
+	}
+	close(c)
+}
+
+// readWarcRecord reads the next record: a version line, header lines up to
+// a blank line, and Content-Length bytes of content.  Header names are
+// lowercased.
+func readWarcRecord(reader *bufio.Reader) (*warcRecord, error) {
+	rec := &warcRecord{header: make(map[string]string)}
+
+	// Skip the blank lines between records to the version line.
+	for {
+		line, err := reader.ReadString('\n')
+		if strings.HasPrefix(line, "WARC/") {
+			rec.raw.WriteString(line)
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		rec.raw.WriteString(line)
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if err != nil {
+				return nil, io.ErrUnexpectedEOF
+			}
+			break
+		}
+		if i := strings.IndexByte(line, ':'); i > 0 {
+			name := strings.ToLower(strings.TrimSpace(line[:i]))
+			rec.header[name] = strings.TrimSpace(line[i+1:])
+		}
+	}
+	rec.header["warc-record-id"] = strings.Trim(rec.header["warc-record-id"], "<>")
+
+	length, err := strconv.Atoi(rec.header["content-length"])
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 || length > WARC_MAX_RECORD {
+		return nil, fmt.Errorf("bad WARC Content-Length %d", length)
+	}
+	rec.content = make([]byte, length)
+	if _, err := io.ReadFull(reader, rec.content); err != nil {
+		return nil, err
+	}
+	rec.raw.Write(rec.content)
+	rec.raw.WriteString("\r\n\r\n")
+	return rec, nil
+}
+
+// httpText returns the text and title of an HTTP response if it is a text
+// document, extracting the main text from HTML.
+func httpText(content []byte) (text, title string, ok bool) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(content)), nil)
+	if err != nil {
+		return "", "", false
+	}
+	defer resp.Body.Close()
+
+	ctype := strings.ToLower(resp.Header.Get("Content-Type"))
+	is_html := ctype == "" || strings.Contains(ctype, "html")
+	if !is_html && !strings.HasPrefix(ctype, "text/") {
+		return "", "", false
+	}
+
+	var body io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return "", "", false
+		}
+		defer gz.Close()
+		body = gz
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil && len(data) == 0 {
+		return "", "", false
+	}
+
+	if !is_html {
+		return string(data), "", true
+	}
+	page := string(data)
+	if m := html_title_re.FindStringSubmatch(page); m != nil {
+		title = htmlText(m[1])
+	}
+	return ExtractText(page), title, true
+}
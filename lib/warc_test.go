@@ -0,0 +1,54 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func makeWarcRecord(header, content string) string {
+	return fmt.Sprintf("WARC/1.0\r\n%sContent-Length: %d\r\n\r\n%s\r\n\r\n", header, len(content), content)
+}
+
+func TestWarcReader(t *testing.T) {
+	page := "<html><head><title>Storm News</title></head><body>" +
+		"<p>A powerful storm hit the coast on Monday, knocking out power.</p></body></html>"
+	response := "HTTP/1.1 200 OK\r\nContent-Type: text/html; charset=utf-8\r\n" +
+		fmt.Sprintf("Content-Length: %d\r\n\r\n", len(page)) + page
+	image := "HTTP/1.1 200 OK\r\nContent-Type: image/png\r\nContent-Length: 4\r\n\r\n\x89PNG"
+
+	input := makeWarcRecord("WARC-Type: warcinfo\r\nWARC-Record-ID: <urn:uuid:0>\r\n", "software: test\r\n") +
+		makeWarcRecord("WARC-Type: request\r\nWARC-Record-ID: <urn:uuid:1>\r\nWARC-Target-URI: http://example.com/\r\n", "GET / HTTP/1.1\r\n\r\n") +
+		makeWarcRecord("WARC-Type: response\r\nWARC-Record-ID: <urn:uuid:2>\r\nWARC-Target-URI: http://example.com/\r\n", response) +
+		makeWarcRecord("WARC-Type: response\r\nWARC-Record-ID: <urn:uuid:3>\r\nWARC-Target-URI: http://example.com/a.png\r\n", image) +
+		makeWarcRecord("WARC-Type: conversion\r\nWARC-Record-ID: <urn:uuid:4>\r\nWARC-Target-URI: http://example.com/b\r\n", "Plain text\nfrom a WET file.")
+
+	c := make(chan Document)
+	go WarcReader{}.Read(bufio.NewReader(strings.NewReader(input)), c)
+	var docs []Document
+	for doc := range c {
+		docs = append(docs, doc)
+	}
+
+	if len(docs) != 2 {
+		t.Fatalf("Expected 2 documents, got %d: %+v", len(docs), docs)
+	}
+	if docs[0].Id != "urn:uuid:2" || docs[0].Name != "Storm News" ||
+		docs[0].Text != "A powerful storm hit the coast on Monday, knocking out power." {
+		t.Errorf("Bad response document %+v", docs[0])
+	}
+	if docs[1].Id != "urn:uuid:4" || docs[1].Name != "http://example.com/b" ||
+		docs[1].Text != "Plain text\nfrom a WET file." {
+		t.Errorf("Bad conversion document %+v", docs[1])
+	}
+}
+
+func TestWarcBadLength(t *testing.T) {
+	for _, length := range []string{"-1", "99999999999"} {
+		input := "WARC/1.0\r\nWARC-Type: conversion\r\nContent-Length: " + length + "\r\n\r\ntext\r\n\r\n"
+		if _, err := readWarcRecord(bufio.NewReader(strings.NewReader(input))); err == nil {
+			t.Errorf("Content-Length %s was accepted", length)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/xml"
+	"html"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// A WikiReader reads pages from a MediaWiki XML dump, such as the
+// Wikipedia pages-articles dumps.  Each page becomes a document made from
+// its latest revision, or with Revisions, each revision becomes a
+// document with the id "pageid#revid".  Wikitext markup is stripped from
+// the text, and the page title is the document name.  Redirects are
+// skipped, and unless AllNamespaces is set, so are pages outside the main
+// article namespace.  The date of a document is its revision's timestamp.
+//
+// The raw record of a page is its <page> element, and what comes before
+// the first page and after the last, the <mediawiki> element and its
+// <siteinfo>, are sent as a header and footer.  Revisions don't have raw
+// records.
+type WikiReader struct {
+	Revisions     bool
+	AllNamespaces bool
+}
+
+type wikiPage struct {
+	Title     string         `xml:"title"`
+	Ns        int            `xml:"ns"`
+	Id        string         `xml:"id"`
+	Redirect  *struct{}      `xml:"redirect"`
+	Revisions []wikiRevision `xml:"revision"`
+}
+
+type wikiRevision struct {
+	Id        string `xml:"id"`
+	Timestamp string `xml:"timestamp"`
+	Text      string `xml:"text"`
+}
+
+// skip says whether a page is left out of the documents.
+func (w WikiReader) skip(page *wikiPage) bool {
+	return page.Redirect != nil || (page.Ns != 0 && !w.AllNamespaces)
+}
+
+// Read sends the pages or revisions in the dump to the channel, and closes
+// the channel at the end.
+func (w WikiReader) Read(reader *bufio.Reader, c chan Document) {
+	defer close(c)
+	rr := &recordingReader{r: reader}
+	decoder := xml.NewDecoder(rr)
+	started := false
+	for {
+		offset := decoder.InputOffset()
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			if !w.Revisions && started {
+				footer := strings.TrimSpace(string(rr.buf))
+				c <- Document{Raw: footer + "\n", Kind: FOOTER}
+			}
+			break
+		}
+		if err != nil {
+			log.Println("Error reading wiki dump:", err)
+			break
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "page" {
+			continue
+		}
+		if !w.Revisions && !started {
+			c <- Document{Raw: rr.cut(rr.base, offset), Kind: HEADER}
+		}
+		started = true
+
+		if w.Revisions {
+			if err := w.readRevisions(decoder, rr, c); err != nil {
+				log.Println("Error reading wiki page:", err)
+				break
+			}
+			continue
+		}
+		var page wikiPage
+		if err := decoder.DecodeElement(&page, &start); err != nil {
+			log.Println("Error reading wiki page:", err)
+			break
+		}
+		raw := rr.cut(offset, decoder.InputOffset())
+		if w.skip(&page) || len(page.Revisions) == 0 {
+			continue
+		}
+		rev := page.Revisions[len(page.Revisions)-1]
+		c <- Document{Text: StripWikitext(rev.Text), Id: page.Id, Name: page.Title, Raw: raw + "\n",
+			Date: wikiTime(rev.Timestamp)}
+	}
+}
+
+// readRevisions reads the rest of a page element, sending each revision as
+// it is decoded, so that only one revision of a full-history dump is in
+// memory at a time.  The title, namespace and id come before the
+// revisions in a dump.
+func (w WikiReader) readRevisions(decoder *xml.Decoder, rr *recordingReader, c chan Document) error {
+	var page wikiPage
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			if _, ok := tok.(xml.EndElement); ok {
+				return nil
+			}
+			continue
+		}
+		switch start.Name.Local {
+		case "title":
+			err = decoder.DecodeElement(&page.Title, &start)
+		case "ns":
+			err = decoder.DecodeElement(&page.Ns, &start)
+		case "id":
+			err = decoder.DecodeElement(&page.Id, &start)
+		case "redirect":
+			page.Redirect = &struct{}{}
+			err = decoder.Skip()
+		case "revision":
+			var rev wikiRevision
+			if err = decoder.DecodeElement(&rev, &start); err == nil && !w.skip(&page) {
+				c <- Document{Text: StripWikitext(rev.Text), Id: page.Id + "#" + rev.Id, Name: page.Title,
+					Date: wikiTime(rev.Timestamp)}
+			}
+			// Revisions have no raw records, so nothing read needs keeping.
+			end := decoder.InputOffset()
+			rr.cut(end, end)
+		default:
+			err = decoder.Skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// wikiTime parses a revision timestamp, returning the zero time if it
+// can't.
+func wikiTime(timestamp string) time.Time {
+	t, _ := time.Parse(time.RFC3339, timestamp)
+	return t
+}
+
+// A recordingReader keeps the bytes read through it, so that the raw text
+// of an element can be cut out using the decoder's offsets.  It is a
+// ByteReader, so the decoder reads no further ahead than it has to.
+type recordingReader struct {
+	r    *bufio.Reader
+	buf  []byte
+	base int64
+}
+
+func (rr *recordingReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	rr.buf = append(rr.buf, p[:n]...)
+	return n, err
+}
+
+func (rr *recordingReader) ReadByte() (byte, error) {
+	b, err := rr.r.ReadByte()
+	if err == nil {
+		rr.buf = append(rr.buf, b)
+	}
+	return b, err
+}
+
+// cut returns the bytes from start to end, and forgets everything before
+// end.
+func (rr *recordingReader) cut(start, end int64) string {
+	result := string(rr.buf[start-rr.base : end-rr.base])
+	rr.buf = append(rr.buf[:0], rr.buf[end-rr.base:]...)
+	rr.base = end
+	return result
+}
+
+var wiki_comment_re = regexp.MustCompile(`(?s)<!--.*?-->`)
+var wiki_ref_re = regexp.MustCompile(`(?is)<ref[^>/]*/>|<ref[^>]*>.*?</ref\s*>`)
+var wiki_skip_re = regexp.MustCompile(`(?is)<(math|gallery|timeline|score|syntaxhighlight|source)[^>]*>.*?</(math|gallery|timeline|score|syntaxhighlight|source)\s*>`)
+var wiki_template_re = regexp.MustCompile(`\{\{[^{}]*\}\}`)
+var wiki_table_re = regexp.MustCompile(`(?s)\{\|([^{]|\{[^|])*?\|\}`)
+var wiki_link_re = regexp.MustCompile(`\[\[([^\[\]]*)\]\]`)
+var wiki_extlink_re = regexp.MustCompile(`\[(?:https?:|ftp:)?//[^\s\]]*\s*([^\]]*)\]`)
+var wiki_heading_re = regexp.MustCompile(`(?m)^=+\s*(.*?)\s*=+\s*$`)
+var wiki_quotes_re = regexp.MustCompile(`'{2,}`)
+var wiki_list_re = regexp.MustCompile(`(?m)^[*#:;]+\s*`)
+var wiki_magic_re = regexp.MustCompile(`__[A-Z]+__`)
+
+// replaceNested applies a regexp that matches innermost constructs until
+// nothing more matches, so that nested templates and links come out.
+func replaceNested(re *regexp.Regexp, s string, repl func(string) string) string {
+	for {
+		next := re.ReplaceAllStringFunc(s, repl)
+		if next == s {
+			return s
+		}
+		s = next
+	}
+}
+
+// wikiLink replaces an internal link with its text.  Links to files,
+// images and categories are removed entirely.
+func wikiLink(link string) string {
+	parts := strings.Split(link[2:len(link)-2], "|")
+	target := strings.ToLower(strings.TrimSpace(parts[0]))
+	for _, ns := range []string{"file:", "image:", "category:", "media:"} {
+		if strings.HasPrefix(target, ns) {
+			return ""
+		}
+	}
+	if strings.Contains(target, ":") && !strings.HasPrefix(target, ":") && len(parts) == 1 {
+		// Interlanguage and interwiki links.
+		return ""
+	}
+	return parts[len(parts)-1]
+}
+
+// StripWikitext removes MediaWiki markup, leaving the running text of a
+// page.  Templates, tables, references, files and categories are dropped,
+// links are replaced by their text, and headings and list items become
+// plain lines.
+func StripWikitext(s string) string {
+	s = wiki_comment_re.ReplaceAllLiteralString(s, "")
+	s = wiki_ref_re.ReplaceAllLiteralString(s, "")
+	s = wiki_skip_re.ReplaceAllLiteralString(s, "")
+	s = replaceNested(wiki_template_re, s, func(string) string { return "" })
+	s = replaceNested(wiki_table_re, s, func(string) string { return "" })
+	s = replaceNested(wiki_link_re, s, wikiLink)
+	s = wiki_extlink_re.ReplaceAllString(s, "$1")
+	s = wiki_heading_re.ReplaceAllString(s, "$1")
+	s = wiki_quotes_re.ReplaceAllLiteralString(s, "")
+	s = wiki_list_re.ReplaceAllLiteralString(s, "")
+	s = wiki_magic_re.ReplaceAllLiteralString(s, "")
+	s = html_re.ReplaceAllLiteralString(s, "")
+	return html.UnescapeString(s)
+}
@@ -0,0 +1,75 @@
+package lib
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestStripWikitext(t *testing.T) {
+	text := `{{Infobox city|name=Springfield|population={{formatnum:30000}}}}
+'''Springfield''' is a [[city]] in [[Illinois|the state of Illinois]].<ref name="a">{{cite web|url=x}}</ref>
+[[File:Springfield.jpg|thumb|The [[skyline]] at night]]
+== History ==
+* Founded in [http://example.com 1821].
+{| class="wikitable"
+| a || b
+|}
+[[Category:Cities]]
+[[de:Springfield]]`
+	got := strings.Fields(StripWikitext(text))
+	want := strings.Fields(`Springfield is a city in the state of Illinois.
+History
+Founded in 1821.`)
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Got %q", strings.Join(got, " "))
+	}
+}
+
+func TestWikiReader(t *testing.T) {
+	dump := `<mediawiki>
+<page><title>Springfield</title><ns>0</ns><id>10</id>
+<revision><id>100</id><text>Old text.</text></revision>
+<revision><id>101</id><text>'''New''' text.</text></revision>
+</page>
+<page><title>Shelbyville</title><ns>0</ns><id>11</id><redirect title="Springfield" />
+<revision><id>102</id><text>#REDIRECT [[Springfield]]</text></revision>
+</page>
+<page><title>Talk:Springfield</title><ns>1</ns><id>12</id>
+<revision><id>103</id><text>Discussion.</text></revision>
+</page>
+</mediawiki>`
+
+	var header, footer string
+	read := func(w WikiReader) []Document {
+		c := make(chan Document)
+		go w.Read(bufio.NewReader(strings.NewReader(dump)), c)
+		var docs []Document
+		for doc := range c {
+			switch doc.Kind {
+			case HEADER:
+				header = doc.Raw
+			case FOOTER:
+				footer = doc.Raw
+			default:
+				docs = append(docs, doc)
+			}
+		}
+		return docs
+	}
+
+	docs := read(WikiReader{})
+	if header != "<mediawiki>\n" || footer != "</mediawiki>\n" {
+		t.Errorf("Bad header %q and footer %q", header, footer)
+	}
+	if len(docs) != 1 || docs[0].Id != "10" || docs[0].Name != "Springfield" || docs[0].Text != "New text." {
+		t.Fatalf("Bad pages %+v", docs)
+	}
+	if !strings.HasPrefix(docs[0].Raw, "<page><title>Springfield") || !strings.HasSuffix(docs[0].Raw, "</revision>\n</page>\n") {
+		t.Errorf("Bad raw page %q", docs[0].Raw)
+	}
+	docs = read(WikiReader{Revisions: true})
+	if len(docs) != 2 || docs[0].Id != "10#100" || docs[1].Id != "10#101" {
+		t.Errorf("Bad revisions %+v", docs)
+	}
+}